@@ -31,7 +31,14 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
-	handlers := handler.New(db)
+	handlers, err := handler.New(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize handlers: %v", err)
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	handlers.StartWorkers(workerCtx, cfg.Worker)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
@@ -39,6 +46,7 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
+	server.RegisterOnShutdown(handlers.CloseStreams)
 
 	go func() {
 		log.Printf("Server starting on port %s", cfg.Server.Port)
@@ -53,6 +61,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
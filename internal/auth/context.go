@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type identityContextKey struct{}
+
+func WithIdentity(ctx context.Context, identity *domain.Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the caller of the request, or nil for public
+// routes.
+func IdentityFromContext(ctx context.Context) *domain.Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(*domain.Identity)
+	return identity
+}
+
+type requestIDContextKey struct{}
+
+// WithRequestID stores the id that ties audit entries to the request that
+// caused them.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+type organizationContextKey struct{}
+
+// WithOrganization stores the tenant every repository query is scoped to.
+func WithOrganization(ctx context.Context, organizationID string) context.Context {
+	return context.WithValue(ctx, organizationContextKey{}, organizationID)
+}
+
+// OrganizationFromContext returns the tenant of the request or worker run,
+// falling back to the default organization.
+func OrganizationFromContext(ctx context.Context) string {
+	if organizationID, _ := ctx.Value(organizationContextKey{}).(string); organizationID != "" {
+		return organizationID
+	}
+	return domain.DefaultOrganizationID
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minForcedRefresh limits reloads triggered by tokens with an unknown key id,
+// so that garbage tokens cannot make us hammer the identity provider.
+const minForcedRefresh = 30 * time.Second
+
+// KeySet is a JWKS loaded from a file or an http(s) URL. Keys are cached and
+// reloaded every refresh interval, and early when a token names a key id we
+// have not seen, so keys rotated by the identity provider are picked up
+// without a restart.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	loadMu sync.Mutex
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	forcedAt time.Time
+}
+
+func NewKeySet(source string, refresh time.Duration) *KeySet {
+	return &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the public key with the given id. An empty kid is accepted when
+// the set holds exactly one key.
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, found, loadedAt, canForce := s.lookup(kid)
+	fresh := !loadedAt.IsZero() && time.Since(loadedAt) < s.refresh
+	if found && fresh {
+		return key, nil
+	}
+	if !found && fresh && !canForce {
+		return nil, errUnknownKey(kid)
+	}
+
+	if err := s.reload(ctx, loadedAt, !found); err != nil {
+		if found {
+			// Keep serving the cached key while the provider is unreachable.
+			log.Printf("Failed to refresh JWKS, using cached keys: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	if key, found, _, _ = s.lookup(kid); found {
+		return key, nil
+	}
+	return nil, errUnknownKey(kid)
+}
+
+func (s *KeySet) lookup(kid string) (key crypto.PublicKey, found bool, loadedAt time.Time, canForce bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, only := range s.keys {
+			key, found = only, true
+		}
+	} else {
+		key, found = s.keys[kid]
+	}
+	canForce = time.Since(s.forcedAt) >= minForcedRefresh
+	return key, found, s.loadedAt, canForce
+}
+
+// reload fetches the set unless another request already reloaded it after
+// seenLoadedAt while this one was waiting for the lock.
+func (s *KeySet) reload(ctx context.Context, seenLoadedAt time.Time, forced bool) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	s.mu.RLock()
+	reloaded := s.loadedAt.After(seenLoadedAt)
+	s.mu.RUnlock()
+	if reloaded {
+		return nil
+	}
+
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if forced {
+		s.forcedAt = time.Now()
+	}
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.loadedAt = time.Now()
+	return nil
+}
+
+func (s *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var body []byte
+	if strings.HasPrefix(s.source, "http://") || strings.HasPrefix(s.source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build JWKS request: %w", err)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+		}
+		if body, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return nil, fmt.Errorf("failed to read JWKS: %w", err)
+		}
+	} else {
+		var err error
+		if body, err = os.ReadFile(s.source); err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+	}
+
+	return parseJWKS(body)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS keeps the RSA and EC signing keys of a JWKS document and skips
+// keys of other types, so that an encryption key in the set does not break
+// authentication.
+func parseJWKS(body []byte) (map[string]crypto.PublicKey, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("bad modulus: %w", err)
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("bad exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("bad x coordinate: %w", err)
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("bad y coordinate: %w", err)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
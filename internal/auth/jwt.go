@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/config"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+// clockSkew is tolerated when checking exp and nbf against our clock.
+const clockSkew = time.Minute
+
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Verifier validates OIDC access tokens signed with a key from the JWKS and
+// turns them into caller identities.
+type Verifier struct {
+	keys          *KeySet
+	issuer        string
+	audience      string
+	userClaim     string
+	orgClaim      string
+	defaultScopes []string
+}
+
+func NewVerifier(cfg config.OIDCConfig) *Verifier {
+	return &Verifier{
+		keys:          NewKeySet(cfg.JWKS, cfg.JWKSRefresh),
+		issuer:        cfg.Issuer,
+		audience:      cfg.Audience,
+		userClaim:     cfg.UserClaim,
+		orgClaim:      cfg.OrgClaim,
+		defaultScopes: cfg.DefaultScopes,
+	}
+}
+
+// LooksLikeJWT tells JWTs apart from API keys presented as bearer tokens.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks the signature, expiry, issuer and audience of the token.
+// Invalid tokens are reported as UNAUTHORIZED; other errors mean the keys
+// could not be loaded.
+func (v *Verifier) Verify(ctx context.Context, token string) (*domain.Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+
+	hash, ok := signingHashes[header.Alg]
+	if !ok {
+		return nil, invalidToken(fmt.Sprintf("unsupported algorithm %q", header.Alg))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature")
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, hasher.Sum(nil), hash, signature) {
+		return nil, invalidToken("bad signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+
+	// Numbers are kept as json.Number so that exp and nbf are read exactly.
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, _ := claims[v.userClaim].(string)
+	if userID == "" {
+		return nil, invalidToken(fmt.Sprintf("claim %q is missing", v.userClaim))
+	}
+
+	// User ids are only unique within an organization, so every token is
+	// bound to one: the one it names, or the default organization, so that a
+	// token without the claim cannot act as the same user id elsewhere.
+	organizationID, _ := claims[v.orgClaim].(string)
+	if organizationID == "" {
+		organizationID = domain.DefaultOrganizationID
+	}
+
+	scopes := tokenScopes(claims)
+	if len(scopes) == 0 {
+		scopes = v.defaultScopes
+	}
+
+	return &domain.Identity{
+		Method:         domain.AuthMethodJWT,
+		Subject:        subject,
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Scopes:         scopes,
+	}, nil
+}
+
+func (v *Verifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return invalidToken("exp claim is required")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return invalidToken("token has expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(clockSkew).Before(nbf) {
+		return invalidToken("token is not valid yet")
+	}
+
+	if v.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return invalidToken("unexpected issuer")
+		}
+	}
+
+	if v.audience != "" && !containsClaimValue(claims["aud"], v.audience) {
+		return invalidToken("unexpected audience")
+	}
+
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest []byte, hash crypto.Hash, signature []byte) bool {
+	switch alg[:2] {
+	case "RS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature) == nil
+	case "ES":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		// JWS encodes ECDSA signatures as fixed-size r || s.
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(ecKey, digest, r, s)
+	}
+	return false
+}
+
+// tokenScopes reads API scopes from the space-separated "scope" claim or the
+// "scp" list. Scopes the service does not know are ignored.
+func tokenScopes(claims map[string]interface{}) []string {
+	var raw []string
+	if scope, ok := claims["scope"].(string); ok {
+		raw = strings.Fields(scope)
+	}
+	switch scp := claims["scp"].(type) {
+	case string:
+		raw = append(raw, strings.Fields(scp)...)
+	case []interface{}:
+		for _, item := range scp {
+			if value, ok := item.(string); ok {
+				raw = append(raw, value)
+			}
+		}
+	}
+
+	scopes := make([]string, 0, len(raw))
+	for _, scope := range raw {
+		if domain.IsValidScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func numericDate(value interface{}) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func containsClaimValue(claim interface{}, want string) bool {
+	switch value := claim.(type) {
+	case string:
+		return value == want
+	case []interface{}:
+		for _, item := range value {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+func invalidToken(reason string) error {
+	return &domain.Error{Code: "UNAUTHORIZED", Message: "invalid token: " + reason}
+}
+
+func errUnknownKey(kid string) error {
+	return invalidToken(fmt.Sprintf("unknown signing key %q", kid))
+}
@@ -2,15 +2,25 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	AppEnv   string
-	Server   ServerConfig
-	Database DatabaseConfig
+	AppEnv       string
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Worker       WorkerConfig
+	Integrations IntegrationsConfig
+	Webhooks     WebhooksConfig
+	Outbox       OutboxConfig
+	SMTP         SMTPConfig
+	Auth         AuthConfig
+	OIDC         OIDCConfig
+	RateLimit    RateLimitConfig
 }
 
 type ServerConfig struct {
@@ -19,6 +29,84 @@ type ServerConfig struct {
 	WriteTimeout time.Duration
 }
 
+type WorkerConfig struct {
+	ReviewQueueInterval     time.Duration
+	AbsenceInterval         time.Duration
+	ReviewSLAInterval       time.Duration
+	StalePRInterval         time.Duration
+	WebhookInterval         time.Duration
+	OutboxInterval          time.Duration
+	OutboxRetentionInterval time.Duration
+	EmailDigestInterval     time.Duration
+}
+
+type IntegrationsConfig struct {
+	GitHubWebhookSecret string
+	GitLabWebhookToken  string
+}
+
+type WebhooksConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+}
+
+// OutboxConfig configures the event relay. An event that fails MaxAttempts
+// times is dead-lettered; published and dead-lettered events are deleted after
+// Retention, and a zero Retention keeps them forever.
+type OutboxConfig struct {
+	Sinks             []string
+	NATSURL           string
+	NATSSubjectPrefix string
+	MaxAttempts       int
+	Retention         time.Duration
+}
+
+type SMTPConfig struct {
+	Host       string
+	Port       string
+	Username   string
+	Password   string
+	From       string
+	DigestHour int
+	Timeout    time.Duration
+}
+
+type AuthConfig struct {
+	BootstrapKey string
+}
+
+type OIDCConfig struct {
+	JWKS          string
+	Issuer        string
+	Audience      string
+	UserClaim     string
+	OrgClaim      string
+	DefaultScopes []string
+	JWKSRefresh   time.Duration
+}
+
+// RateLimit is a token bucket: Rate tokens per second are added up to Burst,
+// and every request takes one. A zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig limits requests per route and caller. Routes are keyed by
+// their pattern, e.g. "POST /pullRequest/create"; the others use Default.
+// PerAddress limits all requests from one client IP before they are
+// authenticated, so that bad or missing credentials are throttled as well.
+// Requests from TrustedProxies are charged to the client they forwarded for,
+// taken from X-Forwarded-For.
+type RateLimitConfig struct {
+	Default        RateLimit
+	Routes         map[string]RateLimit
+	PerAddress     RateLimit
+	TrustedProxies []netip.Prefix
+}
+
 type DatabaseConfig struct {
 	Host     string
 	Port     string
@@ -48,8 +136,77 @@ func Load() (*Config, error) {
 			MaxConns: getEnvAsInt("DB_MAX_CONNS", 25),
 			MinConns: getEnvAsInt("DB_MIN_CONNS", 5),
 		},
+		Worker: WorkerConfig{
+			ReviewQueueInterval:     getEnvAsDuration("WORKER_REVIEW_QUEUE_INTERVAL", time.Minute),
+			AbsenceInterval:         getEnvAsDuration("WORKER_ABSENCE_INTERVAL", time.Minute),
+			ReviewSLAInterval:       getEnvAsDuration("WORKER_REVIEW_SLA_INTERVAL", 5*time.Minute),
+			StalePRInterval:         getEnvAsDuration("WORKER_STALE_PR_INTERVAL", time.Hour),
+			WebhookInterval:         getEnvAsDuration("WORKER_WEBHOOK_INTERVAL", 5*time.Second),
+			OutboxInterval:          getEnvAsDuration("WORKER_OUTBOX_INTERVAL", time.Second),
+			OutboxRetentionInterval: getEnvAsDuration("WORKER_OUTBOX_RETENTION_INTERVAL", time.Hour),
+			EmailDigestInterval:     getEnvAsDuration("WORKER_EMAIL_DIGEST_INTERVAL", 5*time.Minute),
+		},
+		Integrations: IntegrationsConfig{
+			GitHubWebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
+			GitLabWebhookToken:  getEnv("GITLAB_WEBHOOK_TOKEN", ""),
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			InitialBackoff: getEnvAsDuration("WEBHOOK_INITIAL_BACKOFF", 30*time.Second),
+			MaxBackoff:     getEnvAsDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+			Timeout:        getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		},
+		Outbox: OutboxConfig{
+			Sinks:             getEnvAsList("OUTBOX_SINKS", []string{"webhook", "chat"}),
+			NATSURL:           getEnv("NATS_URL", ""),
+			NATSSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "previewer"),
+			MaxAttempts:       getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 50),
+			Retention:         getEnvAsDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		},
+		SMTP: SMTPConfig{
+			Host:       getEnv("SMTP_HOST", ""),
+			Port:       getEnv("SMTP_PORT", "25"),
+			Username:   getEnv("SMTP_USERNAME", ""),
+			Password:   getEnv("SMTP_PASSWORD", ""),
+			From:       getEnv("SMTP_FROM", "pr-reviewer@localhost"),
+			DigestHour: getEnvAsInt("EMAIL_DIGEST_HOUR", 9),
+			Timeout:    getEnvAsDuration("SMTP_TIMEOUT", 10*time.Second),
+		},
+		Auth: AuthConfig{
+			BootstrapKey: getEnv("API_BOOTSTRAP_KEY", ""),
+		},
+		OIDC: OIDCConfig{
+			JWKS:          getEnv("OIDC_JWKS", ""),
+			Issuer:        getEnv("OIDC_ISSUER", ""),
+			Audience:      getEnv("OIDC_AUDIENCE", ""),
+			UserClaim:     getEnv("OIDC_USER_CLAIM", "sub"),
+			OrgClaim:      getEnv("OIDC_ORG_CLAIM", "org"),
+			DefaultScopes: getEnvAsList("OIDC_DEFAULT_SCOPES", []string{"read"}),
+			JWKSRefresh:   getEnvAsDuration("OIDC_JWKS_REFRESH", 10*time.Minute),
+		},
 	}
 
+	cfg.RateLimit = RateLimitConfig{
+		Default: RateLimit{
+			Rate:  getEnvAsFloat("RATE_LIMIT_RATE", 20),
+			Burst: getEnvAsInt("RATE_LIMIT_BURST", 40),
+		},
+		PerAddress: RateLimit{
+			Rate:  getEnvAsFloat("RATE_LIMIT_IP_RATE", 50),
+			Burst: getEnvAsInt("RATE_LIMIT_IP_BURST", 100),
+		},
+	}
+	routeLimits, err := parseRouteRateLimits(getEnvAsList("RATE_LIMIT_ROUTES", defaultRouteRateLimits))
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateLimit.Routes = routeLimits
+	trustedProxies, err := parseTrustedProxies(getEnvAsList("RATE_LIMIT_TRUSTED_PROXIES", nil))
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateLimit.TrustedProxies = trustedProxies
+
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
@@ -67,9 +224,110 @@ func (c *Config) validate() error {
 	if c.Database.Name == "" {
 		return fmt.Errorf("database name is required")
 	}
+	if c.SMTP.DigestHour < 0 || c.SMTP.DigestHour > 23 {
+		return fmt.Errorf("EMAIL_DIGEST_HOUR must be between 0 and 23")
+	}
+	if c.Auth.BootstrapKey != "" && len(c.Auth.BootstrapKey) < 16 {
+		return fmt.Errorf("API_BOOTSTRAP_KEY must be at least 16 characters long")
+	}
+	for _, scope := range c.OIDC.DefaultScopes {
+		switch scope {
+		case "read", "write", "admin":
+		default:
+			return fmt.Errorf("unknown scope %q in OIDC_DEFAULT_SCOPES", scope)
+		}
+	}
+	if err := c.RateLimit.Default.validate("RATE_LIMIT_RATE"); err != nil {
+		return err
+	}
+	if err := c.RateLimit.PerAddress.validate("RATE_LIMIT_IP_RATE"); err != nil {
+		return err
+	}
+	for route, limit := range c.RateLimit.Routes {
+		if err := limit.validate(route); err != nil {
+			return err
+		}
+	}
+	if c.Outbox.MaxAttempts < 1 {
+		return fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.Outbox.Retention < 0 {
+		return fmt.Errorf("OUTBOX_RETENTION must not be negative")
+	}
+	for _, sink := range c.Outbox.Sinks {
+		switch sink {
+		case "webhook", "chat", "log":
+		case "email":
+			if c.SMTP.Host == "" {
+				return fmt.Errorf("SMTP_HOST is required for the email outbox sink")
+			}
+		case "nats":
+			if c.Outbox.NATSURL == "" {
+				return fmt.Errorf("NATS_URL is required for the nats outbox sink")
+			}
+		default:
+			return fmt.Errorf("unknown outbox sink %q", sink)
+		}
+	}
 	return nil
 }
 
+func (l RateLimit) validate(name string) error {
+	if l.Rate < 0 {
+		return fmt.Errorf("rate limit of %s must not be negative", name)
+	}
+	if l.Rate > 0 && l.Burst < 1 {
+		return fmt.Errorf("rate limit of %s needs a burst of at least 1", name)
+	}
+	return nil
+}
+
+// defaultRouteRateLimits keeps PR creation, which takes the most database
+// work, well below the default limit.
+var defaultRouteRateLimits = []string{"POST /pullRequest/create=2:10"}
+
+// parseRouteRateLimits reads entries of the form "<route>=<rate>:<burst>",
+// e.g. "POST /pullRequest/create=2:10".
+func parseRouteRateLimits(entries []string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit, len(entries))
+	for _, entry := range entries {
+		route, value, ok := strings.Cut(entry, "=")
+		rate, burst, hasBurst := strings.Cut(value, ":")
+		if !ok || !hasBurst || strings.TrimSpace(route) == "" {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES entry %q must look like \"<route>=<rate>:<burst>\"", entry)
+		}
+
+		var limit RateLimit
+		var err error
+		if limit.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES entry %q has an invalid rate", entry)
+		}
+		if limit.Burst, err = strconv.Atoi(burst); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES entry %q has an invalid burst", entry)
+		}
+		limits[strings.TrimSpace(route)] = limit
+	}
+	return limits, nil
+}
+
+// parseTrustedProxies reads proxy addresses, given either as a single IP or
+// as a CIDR range, e.g. "10.0.0.0/8".
+func parseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES entry %q is neither an IP nor a CIDR range", entry)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
 func (c *DatabaseConfig) DSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
@@ -91,6 +349,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -3,8 +3,9 @@ package domain
 import "fmt"
 
 type Error struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
 func (e *Error) Error() string {
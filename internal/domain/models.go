@@ -1,18 +1,100 @@
 package domain
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	StrategyRandom      = "random"
+	StrategyRoundRobin  = "round_robin"
+	StrategyLeastLoaded = "least_loaded"
+	StrategyWeighted    = "weighted"
+)
+
+const (
+	DefaultReviewersCount = 2
+	MinReviewersCount     = 1
+	MaxReviewersCount     = 5
+)
+
+var ReviewerStrategies = []string{StrategyRandom, StrategyRoundRobin, StrategyLeastLoaded, StrategyWeighted}
+
+func IsValidReviewerStrategy(strategy string) bool {
+	for _, s := range ReviewerStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	OverloadPolicyReject = "reject"
+	OverloadPolicyQueue  = "queue"
+)
+
+func IsValidOverloadPolicy(policy string) bool {
+	return policy == OverloadPolicyReject || policy == OverloadPolicyQueue
+}
+
+func IsValidReviewersCount(count int) bool {
+	return count >= MinReviewersCount && count <= MaxReviewersCount
+}
+
+func IsValidRequiredApprovals(count int) bool {
+	return count >= 0 && count <= MaxReviewersCount
+}
+
 type Team struct {
-	TeamName string       `json:"team_name" db:"team_name"`
-	Members  []TeamMember `json:"members"`
+	TeamName              string       `json:"team_name" db:"team_name"`
+	ReviewerStrategy      string       `json:"reviewer_strategy,omitempty" db:"reviewer_strategy"`
+	ReviewersCount        int          `json:"reviewers_count,omitempty" db:"reviewers_count"`
+	DefaultMaxOpenReviews int          `json:"default_max_open_reviews,omitempty" db:"default_max_open_reviews"`
+	OverloadPolicy        string       `json:"overload_policy,omitempty" db:"overload_policy"`
+	RequiredApprovals     int          `json:"required_approvals,omitempty" db:"required_approvals"`
+	SLAReminderHours      int          `json:"sla_reminder_hours,omitempty" db:"sla_reminder_hours"`
+	SLAEscalationHours    int          `json:"sla_escalation_hours,omitempty" db:"sla_escalation_hours"`
+	StaleAfterDays        int          `json:"stale_after_days,omitempty" db:"stale_after_days"`
+	StaleCloseAfterDays   int          `json:"stale_close_after_days,omitempty" db:"stale_close_after_days"`
+	FallbackTeams         []string     `json:"fallback_teams,omitempty" db:"-"`
+	Members               []TeamMember `json:"members"`
 }
 
 type TeamMember struct {
-	UserID   string `json:"user_id" db:"user_id"`
-	Username string `json:"username" db:"username"`
-	IsActive bool   `json:"is_active" db:"is_active"`
+	UserID         string `json:"user_id" db:"user_id"`
+	Username       string `json:"username" db:"username"`
+	IsActive       bool   `json:"is_active" db:"is_active"`
+	ReviewWeight   int    `json:"review_weight,omitempty" db:"review_weight"`
+	MaxOpenReviews int    `json:"max_open_reviews,omitempty" db:"max_open_reviews"`
+	Role           string `json:"role,omitempty" db:"role"`
+}
+
+type TeamSettingsUpdate struct {
+	ReviewerStrategy      *string `json:"reviewer_strategy,omitempty"`
+	ReviewersCount        *int    `json:"reviewers_count,omitempty"`
+	DefaultMaxOpenReviews *int    `json:"default_max_open_reviews,omitempty"`
+	OverloadPolicy        *string `json:"overload_policy,omitempty"`
+	RequiredApprovals     *int    `json:"required_approvals,omitempty"`
+	SLAReminderHours      *int    `json:"sla_reminder_hours,omitempty"`
+	SLAEscalationHours    *int    `json:"sla_escalation_hours,omitempty"`
+	StaleAfterDays        *int    `json:"stale_after_days,omitempty"`
+	StaleCloseAfterDays   *int    `json:"stale_close_after_days,omitempty"`
+}
+
+type ReviewerCandidate struct {
+	UserID         string
+	Weight         int
+	OpenReviews    int
+	MaxOpenReviews int
+	LastAssignedAt *time.Time
+}
+
+func (c ReviewerCandidate) AtCapacity() bool {
+	return c.MaxOpenReviews > 0 && c.OpenReviews >= c.MaxOpenReviews
 }
 
 type User struct {
@@ -20,55 +102,542 @@ type User struct {
 	Username string `json:"username" db:"username"`
 	TeamName string `json:"team_name" db:"team_name"`
 	IsActive bool   `json:"is_active" db:"is_active"`
+	Role     string `json:"role,omitempty" db:"role"`
+}
+
+// A team lead leads the team the user belongs to.
+const (
+	RoleAdmin    = "admin"
+	RoleTeamLead = "team_lead"
+	RoleMember   = "member"
+)
+
+var UserRoles = []string{RoleAdmin, RoleTeamLead, RoleMember}
+
+func IsValidRole(role string) bool {
+	for _, r := range UserRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	EmailNotificationsOff       = "off"
+	EmailNotificationsImmediate = "immediate"
+	EmailNotificationsDigest    = "digest"
+)
+
+func IsValidEmailNotifications(mode string) bool {
+	switch mode {
+	case EmailNotificationsOff, EmailNotificationsImmediate, EmailNotificationsDigest:
+		return true
+	}
+	return false
+}
+
+type EmailSettings struct {
+	UserID        string `json:"user_id" db:"user_id"`
+	Username      string `json:"username,omitempty" db:"username"`
+	Email         string `json:"email" db:"email"`
+	Notifications string `json:"email_notifications" db:"email_notifications"`
+}
+
+type Absence struct {
+	ID                  int        `json:"absence_id" db:"id"`
+	UserID              string     `json:"user_id" db:"user_id"`
+	StartsAt            time.Time  `json:"starts_at" db:"starts_at"`
+	EndsAt              time.Time  `json:"ends_at" db:"ends_at"`
+	Reason              string     `json:"reason,omitempty" db:"reason"`
+	ReviewsReassignedAt *time.Time `json:"reviews_reassigned_at,omitempty" db:"reviews_reassigned_at"`
+}
+
+func (a *Absence) IsActiveAt(t time.Time) bool {
+	return !t.Before(a.StartsAt) && t.Before(a.EndsAt)
+}
+
+const (
+	PRStatusDraft  = "DRAFT"
+	PRStatusOpen   = "OPEN"
+	PRStatusMerged = "MERGED"
+	PRStatusClosed = "CLOSED"
+)
+
+const (
+	ReviewStatePending          = "PENDING"
+	ReviewStateApproved         = "APPROVED"
+	ReviewStateChangesRequested = "CHANGES_REQUESTED"
+	ReviewStateCommented        = "COMMENTED"
+)
+
+func IsValidReviewDecision(state string) bool {
+	return state == ReviewStateApproved || state == ReviewStateChangesRequested || state == ReviewStateCommented
+}
+
+type Review struct {
+	ReviewerID string     `json:"reviewer_id" db:"reviewer_id"`
+	State      string     `json:"state" db:"review_state"`
+	AssignedAt time.Time  `json:"assigned_at" db:"assigned_at"`
+	UpdatedAt  *time.Time `json:"state_updated_at,omitempty" db:"state_updated_at"`
+}
+
+const (
+	AssignmentActionAssigned   = "ASSIGNED"
+	AssignmentActionUnassigned = "UNASSIGNED"
+)
+
+const (
+	AssignmentReasonCreate         = "create"
+	AssignmentReasonReassign       = "reassign"
+	AssignmentReasonBulkDeactivate = "bulk-deactivate"
+	AssignmentReasonManual         = "manual"
+	AssignmentReasonEscalation     = "escalation"
+	AssignmentReasonReady          = "ready"
+	AssignmentReasonReopen         = "reopen"
+	AssignmentReasonQueue          = "queue"
+)
+
+const (
+	CloseReasonManual = "manual"
+	CloseReasonStale  = "stale"
+)
+
+type ReviewerAssignmentEvent struct {
+	ID         int64     `json:"id" db:"id"`
+	ReviewerID string    `json:"reviewer_id" db:"reviewer_id"`
+	Action     string    `json:"action" db:"action"`
+	Reason     string    `json:"reason" db:"reason"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	StaleActionMark  = "mark_stale"
+	StaleActionClose = "close"
+)
+
+// StalePR is an open pull request the stale policy of its author's team is
+// about to act on.
+type StalePR struct {
+	PullRequestID   string     `json:"pull_request_id"`
+	PullRequestName string     `json:"pull_request_name"`
+	AuthorID        string     `json:"author_id"`
+	TeamName        string     `json:"team_name"`
+	LastActivityAt  time.Time  `json:"last_activity_at"`
+	StaleAt         *time.Time `json:"stale_at,omitempty"`
+	CloseAfterDays  int        `json:"-"`
+	Action          string     `json:"action"`
+}
+
+// OverdueReview is a pending review assignment together with the SLA of the
+// team that owns the pull request.
+type OverdueReview struct {
+	PullRequestID      string
+	ReviewerID         string
+	AssignedAt         time.Time
+	RemindedAt         *time.Time
+	SLAReminderHours   int
+	SLAEscalationHours int
+}
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderChat   = "chat"
+)
+
+type ExternalIdentity struct {
+	Provider      string `json:"provider" db:"provider"`
+	ExternalLogin string `json:"external_login" db:"external_login"`
+	UserID        string `json:"user_id" db:"user_id"`
+}
+
+type PullRequestSource struct {
+	Provider   string `json:"provider" db:"source_provider"`
+	Project    string `json:"project" db:"source_project"`
+	ExternalID string `json:"external_id" db:"external_id"`
+}
+
+const (
+	EventPRCreated          = "pr.created"
+	EventReviewersAssigned  = "pr.reviewers_assigned"
+	EventReviewerReassigned = "pr.reviewer_reassigned"
+	EventPRMerged           = "pr.merged"
+	EventUserDeactivated    = "user.deactivated"
+	EventUserActivated      = "user.activated"
+	EventReviewOverdue      = "pr.review_overdue"
+	EventPRStale            = "pr.stale"
+	EventPRClosed           = "pr.closed"
+)
+
+var EventTypes = []string{EventPRCreated, EventReviewersAssigned, EventReviewerReassigned, EventPRMerged, EventUserDeactivated, EventUserActivated,
+	EventReviewOverdue, EventPRStale, EventPRClosed}
+
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	ID             int64       `json:"id"`
+	Type           string      `json:"type"`
+	OrganizationID string      `json:"organization_id"`
+	OccurredAt     time.Time   `json:"occurred_at"`
+	Data           interface{} `json:"data"`
+	Attempts       int         `json:"-"`
+	PublishedSinks []string    `json:"-"`
+}
+
+type ReviewersAssignedData struct {
+	PullRequestID string   `json:"pull_request_id"`
+	Reviewers     []string `json:"reviewers"`
+	Reason        string   `json:"reason"`
+}
+
+type ReviewerReassignedData struct {
+	PullRequestID string `json:"pull_request_id"`
+	OldReviewerID string `json:"old_reviewer_id"`
+	NewReviewerID string `json:"new_reviewer_id"`
+	BorrowedFrom  string `json:"borrowed_from,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+type ReviewOverdueData struct {
+	PullRequestID string    `json:"pull_request_id"`
+	ReviewerID    string    `json:"reviewer_id"`
+	AssignedAt    time.Time `json:"assigned_at"`
+	SLAHours      int       `json:"sla_hours"`
+}
+
+type PRStaleData struct {
+	PullRequestID  string    `json:"pull_request_id"`
+	AuthorID       string    `json:"author_id"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	CloseAfterDays int       `json:"close_after_days,omitempty"`
+}
+
+type PRClosedData struct {
+	PullRequestID string `json:"pull_request_id"`
+	Reason        string `json:"reason"`
+}
+
+type UserStatusData struct {
+	UserID   string `json:"user_id"`
+	TeamName string `json:"team_name"`
+	Reason   string `json:"reason"`
+}
+
+// EventCursor is a position in the event stream. Events are ordered by the
+// transaction that wrote them first, so that an event committed late by a slow
+// transaction still sorts after everything a reader has already seen.
+type EventCursor struct {
+	TxID uint64
+	ID   int64
+}
+
+func (c EventCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.TxID, c.ID)
+}
+
+func ParseEventCursor(value string) (EventCursor, error) {
+	txID, id, ok := strings.Cut(value, "-")
+	if !ok {
+		return EventCursor{}, fmt.Errorf("invalid event cursor %q", value)
+	}
+
+	var cursor EventCursor
+	var err error
+	if cursor.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+		return EventCursor{}, fmt.Errorf("invalid event cursor %q", value)
+	}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return EventCursor{}, fmt.Errorf("invalid event cursor %q", value)
+	}
+	return cursor, nil
+}
+
+// TeamChatSettings configures the Slack/Mattermost incoming webhook a team is
+// notified through. Empty templates fall back to the built-in ones.
+type TeamChatSettings struct {
+	TeamName           string `json:"team_name" db:"team_name"`
+	WebhookURL         string `json:"webhook_url" db:"webhook_url"`
+	Channel            string `json:"channel,omitempty" db:"channel"`
+	AssignedTemplate   string `json:"assigned_template,omitempty" db:"assigned_template"`
+	ReassignedTemplate string `json:"reassigned_template,omitempty" db:"reassigned_template"`
+	IsEnabled          bool   `json:"is_enabled" db:"is_enabled"`
+}
+
+const (
+	DeliveryStatusPending   = "PENDING"
+	DeliveryStatusDelivered = "DELIVERED"
+	DeliveryStatusFailed    = "FAILED"
+)
+
+type WebhookSubscription struct {
+	ID        int       `json:"subscription_id" db:"id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"-" db:"secret"`
+	Events    []string  `json:"events" db:"events"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64      `json:"delivery_id" db:"id"`
+	SubscriptionID int        `json:"subscription_id" db:"subscription_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	Payload        []byte     `json:"-" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string    `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var APIKeyScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+func IsValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is bound to OrganizationID; keys without one, like the bootstrap
+// key, may act in any organization.
+type APIKey struct {
+	ID             int        `json:"api_key_id" db:"id"`
+	Name           string     `json:"name" db:"name"`
+	Prefix         string     `json:"prefix" db:"key_prefix"`
+	Scopes         []string   `json:"scopes" db:"scopes"`
+	OrganizationID string     `json:"organization_id,omitempty" db:"organization_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// ScopesAllow reports whether the granted scopes cover the required one.
+// Scopes are ordered: admin implies write, and write implies read.
+func ScopesAllow(granted []string, required string) bool {
+	rank := map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+	for _, scope := range granted {
+		if rank[scope] >= rank[required] {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook and system are not credentials: they name who acted in audit
+// entries written by inbound webhooks and background workers.
+const (
+	AuthMethodAPIKey  = "api_key"
+	AuthMethodJWT     = "jwt"
+	AuthMethodWebhook = "webhook"
+	AuthMethodSystem  = "system"
+)
+
+// Identity is the authenticated caller of a request. UserID is set for
+// tokens issued to a person and empty for API keys, which act as services.
+// OrganizationID is the organization the credential is bound to, if any.
+type Identity struct {
+	Method         string   `json:"method"`
+	Subject        string   `json:"subject"`
+	UserID         string   `json:"user_id,omitempty"`
+	APIKeyID       int      `json:"api_key_id,omitempty"`
+	OrganizationID string   `json:"organization_id,omitempty"`
+	Scopes         []string `json:"scopes"`
+}
+
+func (i Identity) HasScope(required string) bool {
+	return ScopesAllow(i.Scopes, required)
 }
 
 type PullRequest struct {
-	PullRequestID     string     `json:"pull_request_id" db:"pull_request_id"`
-	PullRequestName   string     `json:"pull_request_name" db:"pull_request_name"`
-	AuthorID          string     `json:"author_id" db:"author_id"`
-	Status            string     `json:"status" db:"status"`
-	AssignedReviewers []string   `json:"assigned_reviewers" db:"-"`
-	CreatedAt         *time.Time `json:"createdAt,omitempty" db:"created_at"`
-	MergedAt          *time.Time `json:"mergedAt,omitempty" db:"merged_at"`
+	PullRequestID     string             `json:"pull_request_id" db:"pull_request_id"`
+	PullRequestName   string             `json:"pull_request_name" db:"pull_request_name"`
+	AuthorID          string             `json:"author_id" db:"author_id"`
+	Status            string             `json:"status" db:"status"`
+	AssignedReviewers []string           `json:"assigned_reviewers" db:"-"`
+	Reviews           []Review           `json:"reviews,omitempty" db:"-"`
+	PendingReviewers  int                `json:"pending_reviewers,omitempty" db:"pending_reviewers"`
+	CreatedAt         *time.Time         `json:"createdAt,omitempty" db:"created_at"`
+	MergedAt          *time.Time         `json:"mergedAt,omitempty" db:"merged_at"`
+	ClosedAt          *time.Time         `json:"closedAt,omitempty" db:"closed_at"`
+	LastActivityAt    *time.Time         `json:"lastActivityAt,omitempty" db:"last_activity_at"`
+	StaleAt           *time.Time         `json:"staleAt,omitempty" db:"stale_at"`
+	Source            *PullRequestSource `json:"source,omitempty" db:"-"`
 }
 
 type PullRequestShort struct {
-	PullRequestID   string `json:"pull_request_id"`
-	PullRequestName string `json:"pull_request_name"`
-	AuthorID        string `json:"author_id"`
-	Status          string `json:"status"`
+	PullRequestID   string     `json:"pull_request_id"`
+	PullRequestName string     `json:"pull_request_name"`
+	AuthorID        string     `json:"author_id"`
+	Status          string     `json:"status"`
+	ReviewState     string     `json:"review_state,omitempty"`
+	StateUpdatedAt  *time.Time `json:"state_updated_at,omitempty"`
 }
 
 type TeamDB struct {
-	TeamName  string    `db:"team_name"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	TeamName              string    `db:"team_name"`
+	ReviewerStrategy      string    `db:"reviewer_strategy"`
+	ReviewersCount        int       `db:"reviewers_count"`
+	DefaultMaxOpenReviews *int      `db:"default_max_open_reviews"`
+	OverloadPolicy        string    `db:"overload_policy"`
+	RequiredApprovals     int       `db:"required_approvals"`
+	SLAReminderHours      int       `db:"sla_reminder_hours"`
+	SLAEscalationHours    int       `db:"sla_escalation_hours"`
+	StaleAfterDays        int       `db:"stale_after_days"`
+	StaleCloseAfterDays   int       `db:"stale_close_after_days"`
+	CreatedAt             time.Time `db:"created_at"`
+	UpdatedAt             time.Time `db:"updated_at"`
 }
 
 type UserDB struct {
-	ID        int       `db:"id"`
-	UserID    string    `db:"user_id"`
-	Username  string    `db:"username"`
-	TeamName  string    `db:"team_name"`
-	IsActive  bool      `db:"is_active"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID                 int        `db:"id"`
+	UserID             string     `db:"user_id"`
+	Username           string     `db:"username"`
+	TeamName           string     `db:"team_name"`
+	IsActive           bool       `db:"is_active"`
+	ReviewWeight       int        `db:"review_weight"`
+	MaxOpenReviews     *int       `db:"max_open_reviews"`
+	Email              *string    `db:"email"`
+	EmailNotifications string     `db:"email_notifications"`
+	EmailDigestSentAt  *time.Time `db:"email_digest_sent_at"`
+	CreatedAt          time.Time  `db:"created_at"`
+	UpdatedAt          time.Time  `db:"updated_at"`
 }
 
 type PullRequestDB struct {
-	ID              int        `db:"id"`
-	PullRequestID   string     `db:"pull_request_id"`
-	PullRequestName string     `db:"pull_request_name"`
-	AuthorID        string     `db:"author_id"`
-	Status          string     `db:"status"`
-	CreatedAt       *time.Time `db:"created_at"`
-	MergedAt        *time.Time `db:"merged_at"`
-	UpdatedAt       time.Time  `db:"updated_at"`
+	ID               int        `db:"id"`
+	PullRequestID    string     `db:"pull_request_id"`
+	PullRequestName  string     `db:"pull_request_name"`
+	AuthorID         string     `db:"author_id"`
+	Status           string     `db:"status"`
+	CreatedAt        *time.Time `db:"created_at"`
+	MergedAt         *time.Time `db:"merged_at"`
+	ClosedAt         *time.Time `db:"closed_at"`
+	LastActivityAt   time.Time  `db:"last_activity_at"`
+	StaleAt          *time.Time `db:"stale_at"`
+	PendingReviewers int        `db:"pending_reviewers"`
+	SourceProvider   *string    `db:"source_provider"`
+	SourceProject    *string    `db:"source_project"`
+	ExternalID       *string    `db:"external_id"`
+	UpdatedAt        time.Time  `db:"updated_at"`
+}
+
+type TeamFallbackDB struct {
+	ID               int       `db:"id"`
+	TeamName         string    `db:"team_name"`
+	FallbackTeamName string    `db:"fallback_team_name"`
+	Priority         int       `db:"priority"`
+	CreatedAt        time.Time `db:"created_at"`
 }
 
 type PullRequestReviewerDB struct {
-	ID            int       `db:"id"`
-	PullRequestID string    `db:"pull_request_id"`
-	ReviewerID    string    `db:"reviewer_id"`
-	AssignedAt    time.Time `db:"assigned_at"`
+	ID             int        `db:"id"`
+	PullRequestID  string     `db:"pull_request_id"`
+	ReviewerID     string     `db:"reviewer_id"`
+	AssignedAt     time.Time  `db:"assigned_at"`
+	ReviewState    string     `db:"review_state"`
+	StateUpdatedAt *time.Time `db:"state_updated_at"`
+}
+
+const (
+	AuditActionTeamAdd            = "team.add"
+	AuditActionBulkDeactivate     = "team.bulk_deactivate"
+	AuditActionTeamUpdateSettings = "team.update_settings"
+	AuditActionTeamSetFallbacks   = "team.set_fallbacks"
+	AuditActionTeamSetChat        = "team.set_chat_settings"
+	AuditActionUserSetActive      = "user.set_active"
+	AuditActionUserSetRole        = "user.set_role"
+	AuditActionUserSetMaxReviews  = "user.set_max_open_reviews"
+	AuditActionUserSetEmail       = "user.set_email_settings"
+	AuditActionUserAddAbsence     = "user.add_absence"
+	AuditActionUserDeleteAbsence  = "user.delete_absence"
+	AuditActionUserMapIdentity    = "user.map_identity"
+	AuditActionUserUnmapIdentity  = "user.unmap_identity"
+	AuditActionPRCreate           = "pr.create"
+	AuditActionPRMerge            = "pr.merge"
+	AuditActionPRReassign         = "pr.reassign"
+	AuditActionPRClose            = "pr.close"
+	AuditActionPROpen             = "pr.open"
+	AuditActionPRReview           = "pr.review"
+	AuditActionPRAddReviewers     = "pr.add_reviewers"
+	AuditActionAPIKeyIssue        = "api_key.issue"
+	AuditActionAPIKeyRevoke       = "api_key.revoke"
+	AuditActionOrgCreate          = "organization.create"
+	AuditActionOrgSetSecrets      = "organization.set_webhook_secrets"
+	AuditActionSubscriptionAdd    = "subscription.add"
+	AuditActionSubscriptionDelete = "subscription.delete"
+	AuditActionDeliveryRedeliver  = "subscription.redeliver"
+)
+
+const (
+	AuditEntityTeam         = "team"
+	AuditEntityUser         = "user"
+	AuditEntityPullRequest  = "pull_request"
+	AuditEntityAPIKey       = "api_key"
+	AuditEntityOrg          = "organization"
+	AuditEntitySubscription = "webhook_subscription"
+	AuditEntityDelivery     = "webhook_delivery"
+)
+
+// AuditEntry records who changed what. ActorID is the user id for personal
+// tokens and the key id for API keys; Before and After hold the changed
+// values as JSON.
+type AuditEntry struct {
+	ID          int64           `json:"audit_id"`
+	RequestID   string          `json:"request_id,omitempty"`
+	ActorMethod string          `json:"actor_method"`
+	ActorID     string          `json:"actor_id,omitempty"`
+	ActorName   string          `json:"actor_name"`
+	Action      string          `json:"action"`
+	EntityType  string          `json:"entity_type"`
+	EntityID    string          `json:"entity_id"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit entries; empty fields match everything. Entries
+// are returned newest first, BeforeID continues from the last page.
+type AuditFilter struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	Actor      string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+// DefaultOrganizationID holds the data created before organizations existed
+// and serves requests that do not name an organization.
+const DefaultOrganizationID = "default"
+
+// Organization carries the secrets its inbound GitHub/GitLab webhooks are
+// verified with; they are never returned by the API.
+type Organization struct {
+	OrganizationID      string     `json:"organization_id" db:"organization_id"`
+	Name                string     `json:"name" db:"name"`
+	GitHubWebhookSecret string     `json:"-" db:"github_webhook_secret"`
+	GitLabWebhookToken  string     `json:"-" db:"gitlab_webhook_token"`
+	CreatedAt           *time.Time `json:"created_at,omitempty" db:"created_at"`
 }
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/service"
+)
+
+type AbsenceHandler struct {
+	*BaseHandler
+	absenceService *service.AbsenceService
+	policy         *service.AccessPolicy
+}
+
+func NewAbsenceHandler(absenceService *service.AbsenceService, policy *service.AccessPolicy) *AbsenceHandler {
+	return &AbsenceHandler{
+		BaseHandler:    &BaseHandler{},
+		absenceService: absenceService,
+		policy:         policy,
+	}
+}
+
+func (h *AbsenceHandler) AddAbsence(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		UserID   string    `json:"user_id"`
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at"`
+		Reason   string    `json:"reason,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.UserID == "" || request.StartsAt.IsZero() || request.EndsAt.IsZero() {
+		h.writeError(w, http.StatusBadRequest, "user_id, starts_at and ends_at are required", "MISSING_PARAMETER")
+		return
+	}
+
+	if err := h.policy.CanEditUser(r.Context(), auth.IdentityFromContext(r.Context()), request.UserID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	absence := &domain.Absence{
+		UserID:   request.UserID,
+		StartsAt: request.StartsAt,
+		EndsAt:   request.EndsAt,
+		Reason:   request.Reason,
+	}
+
+	if err := h.absenceService.AddAbsence(r.Context(), absence); err != nil {
+		switch {
+		case domain.IsDomainError(err, "NOT_FOUND"):
+			h.writeError(w, http.StatusNotFound, "user not found", "NOT_FOUND")
+		case domain.IsDomainError(err, "INVALID_PERIOD"):
+			h.writeError(w, http.StatusBadRequest, err.(*domain.Error).Message, "INVALID_PERIOD")
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"absence": absence,
+	})
+}
+
+func (h *AbsenceHandler) GetAbsences(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		h.writeError(w, http.StatusBadRequest, "user_id parameter is required", "MISSING_PARAMETER")
+		return
+	}
+
+	absences, err := h.absenceService.GetUserAbsences(r.Context(), userID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "user not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":  userID,
+		"absences": absences,
+	})
+}
+
+func (h *AbsenceHandler) DeleteAbsence(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		AbsenceID int `json:"absence_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	absence, err := h.absenceService.GetAbsence(r.Context(), request.AbsenceID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "absence not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	if err := h.policy.CanEditUser(r.Context(), auth.IdentityFromContext(r.Context()), absence.UserID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	if err := h.absenceService.DeleteAbsence(r.Context(), request.AbsenceID); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "absence not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"absence_id": request.AbsenceID,
+		"deleted":    true,
+	})
+}
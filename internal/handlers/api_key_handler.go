@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/service"
+)
+
+type APIKeyHandler struct {
+	*BaseHandler
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		BaseHandler:   &BaseHandler{},
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *APIKeyHandler) IssueKey(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.Name == "" {
+		h.writeError(w, http.StatusBadRequest, "name is required", "MISSING_PARAMETER")
+		return
+	}
+
+	key, plaintext, err := h.apiKeyService.IssueKey(r.Context(), request.Name, request.Scopes)
+	if err != nil {
+		if domain.IsDomainError(err, "INVALID_SCOPE") {
+			domainErr := err.(*domain.Error)
+			h.writeErrorWithDetails(w, http.StatusBadRequest, domainErr.Message, "INVALID_SCOPE", domainErr.Details)
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	// The plaintext key is only ever returned here.
+	h.writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"api_key": key,
+		"key":     plaintext,
+	})
+}
+
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeyService.ListKeys(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"api_keys": keys,
+	})
+}
+
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		APIKeyID int `json:"api_key_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	key, err := h.apiKeyService.RevokeKey(r.Context(), request.APIKeyID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "API key not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"api_key": key,
+	})
+}
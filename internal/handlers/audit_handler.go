@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+type AuditHandler struct {
+	*BaseHandler
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditHandler(auditRepo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		BaseHandler: &BaseHandler{},
+		auditRepo:   auditRepo,
+	}
+}
+
+func (h *AuditHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := domain.AuditFilter{
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		EntityID:   query.Get("entity_id"),
+		Actor:      query.Get("actor"),
+		RequestID:  query.Get("request_id"),
+		Limit:      defaultAuditLimit,
+	}
+
+	var err error
+	if filter.From, err = parseOptionalTime(query.Get("from")); err != nil {
+		h.writeError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp", "INVALID_REQUEST")
+		return
+	}
+	if filter.To, err = parseOptionalTime(query.Get("to")); err != nil {
+		h.writeError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp", "INVALID_REQUEST")
+		return
+	}
+
+	if value := query.Get("before_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			h.writeError(w, http.StatusBadRequest, "before_id must be a positive integer", "INVALID_REQUEST")
+			return
+		}
+		filter.BeforeID = id
+	}
+
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			h.writeError(w, http.StatusBadRequest, "limit must be between 1 and 500", "INVALID_REQUEST")
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.auditRepo.ListEntries(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	response := map[string]interface{}{
+		"entries": entries,
+	}
+	// A full page may have more entries behind it.
+	if len(entries) == filter.Limit {
+		response["next_before_id"] = entries[len(entries)-1].ID
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
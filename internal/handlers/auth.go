@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+// scopePublic marks routes that are served without credentials: the health
+// check and the inbound webhooks, which carry their own signatures.
+const scopePublic = ""
+
+const organizationHeader = "X-Organization-ID"
+
+// handle registers a route together with the scope a caller needs to call it.
+func (h *Handler) handle(pattern, scope string, handlerFunc http.HandlerFunc) {
+	h.mux.HandleFunc(pattern, handlerFunc)
+	h.routeScopes[pattern] = scope
+}
+
+// authenticate checks the caller against the scope of the matched route and
+// returns the request with the caller identity and organization stored in its
+// context. Unknown routes are passed through so that the mux answers them
+// with 404 or 405.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	_, pattern := h.mux.Handler(r)
+	scope, ok := h.routeScopes[pattern]
+	if !ok {
+		return r, true
+	}
+	// Inbound webhooks resolve their organization from the URL themselves,
+	// since it decides which secret they are verified with.
+	if scope == scopePublic {
+		return r.WithContext(auth.WithOrganization(r.Context(), domain.DefaultOrganizationID)), true
+	}
+
+	identity, err := h.identify(r)
+	if err != nil {
+		if domain.IsDomainError(err, "UNAUTHORIZED") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="previewer"`)
+			h.writeError(w, http.StatusUnauthorized, err.(*domain.Error).Message, "UNAUTHORIZED")
+			return nil, false
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return nil, false
+	}
+
+	if !identity.HasScope(scope) {
+		h.writeError(w, http.StatusForbidden, fmt.Sprintf("caller lacks the %s scope", scope), "FORBIDDEN")
+		return nil, false
+	}
+
+	return h.withOrganization(w, r.WithContext(auth.WithIdentity(r.Context(), identity)), identity)
+}
+
+// withOrganization resolves the organization every query of the request is
+// scoped to. A credential bound to an organization acts only in it; unbound
+// credentials choose one with the X-Organization-ID header, or the
+// organization_id query parameter where no header can be set. Requests that
+// name none work in the default organization.
+func (h *Handler) withOrganization(w http.ResponseWriter, r *http.Request, identity *domain.Identity) (*http.Request, bool) {
+	requested := r.Header.Get(organizationHeader)
+	if requested == "" {
+		requested = r.URL.Query().Get("organization_id")
+	}
+
+	organizationID := requested
+	if identity != nil && identity.OrganizationID != "" {
+		if requested != "" && requested != identity.OrganizationID {
+			h.writeError(w, http.StatusForbidden, fmt.Sprintf("credential is bound to organization %s", identity.OrganizationID), "FORBIDDEN")
+			return nil, false
+		}
+		organizationID = identity.OrganizationID
+	}
+
+	// The default organization always exists, so requests that do not use
+	// organizations need no extra query.
+	if organizationID == "" {
+		organizationID = domain.DefaultOrganizationID
+	}
+	if organizationID != domain.DefaultOrganizationID {
+		exists, err := h.orgRepo.OrganizationExists(r.Context(), organizationID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+			return nil, false
+		}
+		if !exists {
+			h.writeError(w, http.StatusNotFound, "organization not found", "NOT_FOUND")
+			return nil, false
+		}
+	}
+
+	return r.WithContext(auth.WithOrganization(r.Context(), organizationID)), true
+}
+
+// identify accepts an OIDC access token when a JWKS is configured, and an API
+// key otherwise. Both come as "Authorization: Bearer", API keys may also be
+// sent in the X-API-Key header.
+func (h *Handler) identify(r *http.Request) (*domain.Identity, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			credential = strings.TrimSpace(token)
+		}
+	}
+
+	if h.tokenVerifier != nil && auth.LooksLikeJWT(credential) {
+		return h.tokenVerifier.Verify(r.Context(), credential)
+	}
+
+	key, err := h.apiKeyService.Authenticate(r.Context(), credential)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Identity{
+		Method:         domain.AuthMethodAPIKey,
+		Subject:        key.Name,
+		APIKeyID:       key.ID,
+		OrganizationID: key.OrganizationID,
+		Scopes:         key.Scopes,
+	}, nil
+}
+
+// callerUserID is the user behind the request, if the caller authenticated
+// with a personal token rather than an API key.
+func callerUserID(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.UserID
+	}
+	return ""
+}
+
+func (h *Handler) whoAmI(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"identity": auth.IdentityFromContext(r.Context()),
+	})
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
 )
 
 type BaseHandler struct{}
@@ -24,3 +26,26 @@ func (h *BaseHandler) writeError(w http.ResponseWriter, status int, message, cod
 		},
 	})
 }
+
+func (h *BaseHandler) writeErrorWithDetails(w http.ResponseWriter, status int, message, code string, details map[string]interface{}) {
+	h.writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"details": details,
+		},
+	})
+}
+
+// writeAccessError answers a request refused by the access policy. The policy
+// may also fail to find the user or PR it was asked about.
+func (h *BaseHandler) writeAccessError(w http.ResponseWriter, err error) {
+	switch {
+	case domain.IsDomainError(err, "FORBIDDEN"):
+		h.writeError(w, http.StatusForbidden, err.(*domain.Error).Message, "FORBIDDEN")
+	case domain.IsDomainError(err, "NOT_FOUND"):
+		h.writeError(w, http.StatusNotFound, err.(*domain.Error).Message, "NOT_FOUND")
+	default:
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+	}
+}
@@ -4,18 +4,21 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/pavel/avitotech_previewer/internal/auth"
 	"github.com/pavel/avitotech_previewer/internal/service"
 )
 
 type BulkDeactivationHandler struct {
 	*BaseHandler
 	bulkService *service.BulkDeactivationService
+	policy      *service.AccessPolicy
 }
 
-func NewBulkDeactivationHandler(bulkService *service.BulkDeactivationService) *BulkDeactivationHandler {
+func NewBulkDeactivationHandler(bulkService *service.BulkDeactivationService, policy *service.AccessPolicy) *BulkDeactivationHandler {
 	return &BulkDeactivationHandler{
 		BaseHandler: &BaseHandler{},
 		bulkService: bulkService,
+		policy:      policy,
 	}
 }
 
@@ -35,6 +38,18 @@ func (h *BulkDeactivationHandler) BulkDeactivateTeam(w http.ResponseWriter, r *h
 		return
 	}
 
+	if err := h.policy.CanManageTeam(r.Context(), auth.IdentityFromContext(r.Context()), request.TeamName); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	outranking, err := h.policy.OutrankingMembers(r.Context(), auth.IdentityFromContext(r.Context()), request.TeamName)
+	if err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+	request.ExcludeUserIDs = append(request.ExcludeUserIDs, outranking...)
+
 	result, err := h.bulkService.BulkDeactivateTeam(r.Context(), request.TeamName, request.ExcludeUserIDs)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/service"
+)
+
+const (
+	eventStreamPollInterval = time.Second
+	eventStreamHeartbeat    = 15 * time.Second
+	eventStreamRetry        = 3 * time.Second
+)
+
+type EventStreamHandler struct {
+	*BaseHandler
+	streamService *service.EventStreamService
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+func NewEventStreamHandler(streamService *service.EventStreamService) *EventStreamHandler {
+	return &EventStreamHandler{
+		BaseHandler:   &BaseHandler{},
+		streamService: streamService,
+		done:          make(chan struct{}),
+	}
+}
+
+// Close ends all open streams; http.Server.Shutdown would otherwise wait for
+// them until its deadline.
+func (h *EventStreamHandler) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+func (h *EventStreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	filter := service.EventFilter{
+		TeamName: r.URL.Query().Get("team_name"),
+		UserID:   r.URL.Query().Get("user_id"),
+	}
+
+	// EventSource sends Last-Event-ID on reconnect; the query parameter is for
+	// clients that cannot set headers.
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var cursor domain.EventCursor
+	var err error
+	if lastEventID != "" {
+		cursor, err = domain.ParseEventCursor(lastEventID)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid Last-Event-ID", "INVALID_REQUEST")
+			return
+		}
+	} else {
+		cursor, err = h.streamService.CurrentCursor(r.Context())
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// The server write timeout is meant for regular requests, not streams.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "streaming is not supported", "INTERNAL_ERROR")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	poll := time.NewTicker(eventStreamPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-poll.C:
+			events, err := h.streamService.EventsAfter(r.Context(), cursor, filter)
+			if err != nil {
+				if r.Context().Err() == nil {
+					log.Printf("Failed to read event stream: %v", err)
+				}
+				continue
+			}
+			if len(events) == 0 {
+				continue
+			}
+
+			for _, event := range events {
+				data, err := json.Marshal(event.Event)
+				if err != nil {
+					log.Printf("Failed to encode event %d: %v", event.ID, err)
+					cursor = event.Cursor
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Cursor, event.Type, data); err != nil {
+					return
+				}
+				cursor = event.Cursor
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -1,67 +1,270 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
 
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/config"
 	"github.com/pavel/avitotech_previewer/internal/database"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/ratelimit"
 	"github.com/pavel/avitotech_previewer/internal/repository"
 	"github.com/pavel/avitotech_previewer/internal/service"
+	"github.com/pavel/avitotech_previewer/internal/worker"
 )
 
 type Handler struct {
 	*BaseHandler
 	db                      *database.DB
 	mux                     *http.ServeMux
+	routeScopes             map[string]string
+	apiKeyService           *service.APIKeyService
+	tokenVerifier           *auth.Verifier
+	prService               *service.PullRequestService
+	absenceService          *service.AbsenceService
+	reviewSLAService        *service.ReviewSLAService
+	stalePRService          *service.StalePRService
+	emailNotifier           *service.EmailNotifier
+	webhookService          *service.WebhookService
+	outboxRelay             *service.OutboxRelay
 	teamHandler             *TeamHandler
 	userHandler             *UserHandler
 	prHandler               *PullRequestHandler
 	statsHandler            *StatsHandler
 	bulkDeactivationHandler *BulkDeactivationHandler
+	absenceHandler          *AbsenceHandler
+	integrationHandler      *IntegrationHandler
+	webhookHandler          *WebhookHandler
+	eventStreamHandler      *EventStreamHandler
+	notificationHandler     *NotificationHandler
+	apiKeyHandler           *APIKeyHandler
+	auditHandler            *AuditHandler
+	organizationHandler     *OrganizationHandler
+	orgRepo                 *repository.OrganizationRepository
+	rateLimiter             *ratelimit.Limiter
+	trustedProxies          []netip.Prefix
 }
 
-func New(db *database.DB) *Handler {
+func New(db *database.DB, cfg *config.Config) (*Handler, error) {
 	teamRepo := repository.NewTeamRepository(db.DB)
 	userRepo := repository.NewUserRepository(db.DB)
 	prRepo := repository.NewPullRequestRepository(db.DB)
-	prService := service.NewPullRequestService(prRepo, userRepo)
+	webhookRepo := repository.NewWebhookRepository(db.DB)
+	webhookService := service.NewWebhookService(webhookRepo, cfg.Webhooks)
+	prService := service.NewPullRequestService(prRepo, userRepo, teamRepo)
 	statsRepo := repository.NewStatsRepository(db.DB)
 	bulkService := service.NewBulkDeactivationService(userRepo, prRepo, prService)
+	absenceRepo := repository.NewAbsenceRepository(db.DB)
+	absenceService := service.NewAbsenceService(absenceRepo, userRepo, prRepo, prService)
+	identityRepo := repository.NewExternalIdentityRepository(db.DB)
+	integrationService := service.NewIntegrationService(identityRepo, prService)
+
+	chatNotifier := service.NewChatNotifier(repository.NewChatSettingsRepository(db.DB), identityRepo, prRepo, cfg.Webhooks.Timeout)
+	emailNotifier := service.NewEmailNotifier(userRepo, prRepo, cfg.SMTP)
+
+	sinks, err := newEventSinks(cfg.Outbox, webhookService, chatNotifier, emailNotifier)
+	if err != nil {
+		return nil, err
+	}
+	outboxRepo := repository.NewOutboxRepository(db.DB)
+	outboxRelay := service.NewOutboxRelay(outboxRepo, cfg.Outbox, sinks...)
+	eventStreamService := service.NewEventStreamService(outboxRepo)
+	stalePRService := service.NewStalePRService(prRepo, prService)
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(db.DB), cfg.Auth)
+	policy := service.NewAccessPolicy(userRepo, prRepo)
+	orgRepo := repository.NewOrganizationRepository(db.DB)
 
 	h := &Handler{
 		BaseHandler:             &BaseHandler{},
 		db:                      db,
 		mux:                     http.NewServeMux(),
-		teamHandler:             NewTeamHandler(teamRepo),
-		userHandler:             NewUserHandler(userRepo, prService),
-		prHandler:               NewPullRequestHandler(prService),
+		routeScopes:             make(map[string]string),
+		apiKeyService:           apiKeyService,
+		prService:               prService,
+		absenceService:          absenceService,
+		reviewSLAService:        service.NewReviewSLAService(prRepo, prService),
+		stalePRService:          stalePRService,
+		emailNotifier:           emailNotifier,
+		webhookService:          webhookService,
+		outboxRelay:             outboxRelay,
+		teamHandler:             NewTeamHandler(teamRepo, policy),
+		userHandler:             NewUserHandler(userRepo, prService, emailNotifier, policy),
+		prHandler:               NewPullRequestHandler(prService, stalePRService, policy),
 		statsHandler:            NewStatsHandler(statsRepo),
-		bulkDeactivationHandler: NewBulkDeactivationHandler(bulkService),
+		bulkDeactivationHandler: NewBulkDeactivationHandler(bulkService, policy),
+		absenceHandler:          NewAbsenceHandler(absenceService, policy),
+		integrationHandler:      NewIntegrationHandler(integrationService, orgRepo, cfg.Integrations),
+		webhookHandler:          NewWebhookHandler(webhookService),
+		eventStreamHandler:      NewEventStreamHandler(eventStreamService),
+		notificationHandler:     NewNotificationHandler(chatNotifier),
+		apiKeyHandler:           NewAPIKeyHandler(apiKeyService),
+		auditHandler:            NewAuditHandler(repository.NewAuditRepository(db.DB)),
+		organizationHandler:     NewOrganizationHandler(orgRepo),
+		orgRepo:                 orgRepo,
+		rateLimiter:             ratelimit.New(cfg.RateLimit),
+		trustedProxies:          cfg.RateLimit.TrustedProxies,
+	}
+
+	if cfg.OIDC.JWKS != "" {
+		h.tokenVerifier = auth.NewVerifier(cfg.OIDC)
 	}
 
 	h.registerRoutes()
-	return h
+
+	for route := range cfg.RateLimit.Routes {
+		if _, ok := h.routeScopes[route]; !ok {
+			return nil, fmt.Errorf("unknown route %q in RATE_LIMIT_ROUTES", route)
+		}
+	}
+
+	return h, nil
+}
+
+func newEventSinks(cfg config.OutboxConfig, webhookService *service.WebhookService, chatNotifier *service.ChatNotifier, emailNotifier *service.EmailNotifier) ([]service.EventSink, error) {
+	sinks := make([]service.EventSink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "webhook":
+			sinks = append(sinks, webhookService)
+		case "chat":
+			sinks = append(sinks, chatNotifier)
+		case "email":
+			sinks = append(sinks, emailNotifier)
+		case "log":
+			sinks = append(sinks, service.LogSink{})
+		case "nats":
+			natsSink, err := service.NewNATSSink(cfg.NATSURL, cfg.NATSSubjectPrefix)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, natsSink)
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
 }
 
 func (h *Handler) registerRoutes() {
 
-	h.mux.HandleFunc("GET /health", h.healthCheck)
+	h.handle("GET /health", scopePublic, h.healthCheck)
+	h.handle("GET /auth/whoami", domain.ScopeRead, h.whoAmI)
+
+	h.handle("POST /team/add", domain.ScopeWrite, h.teamHandler.AddTeam)
+	h.handle("GET /team/get", domain.ScopeRead, h.teamHandler.GetTeam)
+	h.handle("POST /team/updateSettings", domain.ScopeWrite, h.teamHandler.UpdateSettings)
+	h.handle("POST /team/setFallbacks", domain.ScopeWrite, h.teamHandler.SetFallbackTeams)
+	h.handle("POST /team/setChatSettings", domain.ScopeWrite, h.notificationHandler.SetChatSettings)
+	h.handle("GET /team/getChatSettings", domain.ScopeRead, h.notificationHandler.GetChatSettings)
+
+	h.handle("POST /users/setIsActive", domain.ScopeWrite, h.userHandler.SetUserActive)
+	h.handle("POST /users/setMaxOpenReviews", domain.ScopeWrite, h.userHandler.SetMaxOpenReviews)
+	h.handle("POST /users/setRole", domain.ScopeWrite, h.userHandler.SetRole)
+	h.handle("POST /users/setEmailSettings", domain.ScopeWrite, h.userHandler.SetEmailSettings)
+	h.handle("GET /users/getReview", domain.ScopeRead, h.userHandler.GetUserReviews)
+	h.handle("POST /users/addAbsence", domain.ScopeWrite, h.absenceHandler.AddAbsence)
+	h.handle("GET /users/getAbsences", domain.ScopeRead, h.absenceHandler.GetAbsences)
+	h.handle("POST /users/deleteAbsence", domain.ScopeWrite, h.absenceHandler.DeleteAbsence)
+
+	h.handle("POST /pullRequest/create", domain.ScopeWrite, h.prHandler.CreatePR)
+	h.handle("POST /pullRequest/merge", domain.ScopeWrite, h.prHandler.MergePR)
+	h.handle("POST /pullRequest/reassign", domain.ScopeWrite, h.prHandler.ReassignPR)
+	h.handle("POST /pullRequest/review", domain.ScopeWrite, h.prHandler.SubmitReview)
+	h.handle("POST /pullRequest/close", domain.ScopeWrite, h.prHandler.ClosePR)
+	h.handle("POST /pullRequest/reopen", domain.ScopeWrite, h.prHandler.ReopenPR)
+	h.handle("POST /pullRequest/markReady", domain.ScopeWrite, h.prHandler.MarkReady)
+	h.handle("GET /pullRequest/get", domain.ScopeRead, h.prHandler.GetPR)
+	h.handle("GET /pullRequest/history", domain.ScopeRead, h.prHandler.GetHistory)
+	h.handle("GET /pullRequest/staleDryRun", domain.ScopeRead, h.prHandler.StaleDryRun)
+
+	h.handle("GET /stats", domain.ScopeRead, h.statsHandler.GetStats)
+
+	h.handle("POST /team/bulkDeactivate", domain.ScopeWrite, h.bulkDeactivationHandler.BulkDeactivateTeam)
 
-	h.mux.HandleFunc("POST /team/add", h.teamHandler.AddTeam)
-	h.mux.HandleFunc("GET /team/get", h.teamHandler.GetTeam)
+	h.handle("POST /webhooks/github", scopePublic, h.integrationHandler.GitHubWebhook)
+	h.handle("POST /webhooks/gitlab", scopePublic, h.integrationHandler.GitLabWebhook)
+	h.handle("POST /webhooks/github/{organization_id}", scopePublic, h.integrationHandler.GitHubWebhook)
+	h.handle("POST /webhooks/gitlab/{organization_id}", scopePublic, h.integrationHandler.GitLabWebhook)
+	h.handle("POST /integrations/mapUser", domain.ScopeAdmin, h.integrationHandler.MapUser)
+	h.handle("POST /integrations/unmapUser", domain.ScopeAdmin, h.integrationHandler.UnmapUser)
+	h.handle("GET /integrations/mappings", domain.ScopeRead, h.integrationHandler.ListMappings)
 
-	h.mux.HandleFunc("POST /users/setIsActive", h.userHandler.SetUserActive)
-	h.mux.HandleFunc("GET /users/getReview", h.userHandler.GetUserReviews)
+	h.handle("POST /subscriptions/add", domain.ScopeAdmin, h.webhookHandler.AddSubscription)
+	h.handle("GET /subscriptions/list", domain.ScopeAdmin, h.webhookHandler.ListSubscriptions)
+	h.handle("POST /subscriptions/delete", domain.ScopeAdmin, h.webhookHandler.DeleteSubscription)
+	h.handle("GET /subscriptions/deliveries", domain.ScopeAdmin, h.webhookHandler.GetDeliveries)
+	h.handle("POST /subscriptions/redeliver", domain.ScopeAdmin, h.webhookHandler.Redeliver)
 
-	h.mux.HandleFunc("POST /pullRequest/create", h.prHandler.CreatePR)
-	h.mux.HandleFunc("POST /pullRequest/merge", h.prHandler.MergePR)
-	h.mux.HandleFunc("POST /pullRequest/reassign", h.prHandler.ReassignPR)
+	h.handle("GET /events/stream", domain.ScopeRead, h.eventStreamHandler.Stream)
 
-	h.mux.HandleFunc("GET /stats", h.statsHandler.GetStats)
+	h.handle("POST /notifications/preview", domain.ScopeRead, h.notificationHandler.Preview)
 
-	h.mux.HandleFunc("POST /team/bulkDeactivate", h.bulkDeactivationHandler.BulkDeactivateTeam)
+	h.handle("POST /apiKeys/issue", domain.ScopeAdmin, h.apiKeyHandler.IssueKey)
+	h.handle("GET /apiKeys/list", domain.ScopeAdmin, h.apiKeyHandler.ListKeys)
+	h.handle("POST /apiKeys/revoke", domain.ScopeAdmin, h.apiKeyHandler.RevokeKey)
+
+	h.handle("GET /audit", domain.ScopeAdmin, h.auditHandler.ListEntries)
+
+	h.handle("POST /organizations/add", domain.ScopeAdmin, h.organizationHandler.AddOrganization)
+	h.handle("GET /organizations/list", domain.ScopeAdmin, h.organizationHandler.ListOrganizations)
+	h.handle("POST /organizations/setWebhookSecrets", domain.ScopeAdmin, h.organizationHandler.SetWebhookSecrets)
+}
+
+// StartWorkers runs the jobs that act on teams, users and PRs once per
+// organization. The outbox relay, its retention and webhook deliveries work on
+// queues shared by all organizations.
+func (h *Handler) StartWorkers(ctx context.Context, cfg config.WorkerConfig) {
+	go worker.Run(ctx, "review-queue", cfg.ReviewQueueInterval, h.forEachOrganization(h.prService.ProcessReviewQueue))
+	go worker.Run(ctx, "absences", cfg.AbsenceInterval, h.forEachOrganization(h.absenceService.ProcessStartedAbsences))
+	go worker.Run(ctx, "review-sla", cfg.ReviewSLAInterval, h.forEachOrganization(h.reviewSLAService.ProcessOverdueReviews))
+	go worker.Run(ctx, "stale-prs", cfg.StalePRInterval, h.forEachOrganization(h.stalePRService.ProcessStalePRs))
+	go worker.Run(ctx, "outbox-relay", cfg.OutboxInterval, h.outboxRelay.ProcessOutbox)
+	go worker.Run(ctx, "outbox-retention", cfg.OutboxRetentionInterval, h.outboxRelay.PurgeOutbox)
+	go worker.Run(ctx, "webhook-deliveries", cfg.WebhookInterval, h.webhookService.ProcessDeliveries)
+	go worker.Run(ctx, "email-digest", cfg.EmailDigestInterval, h.forEachOrganization(h.emailNotifier.ProcessDigests))
+}
+
+// forEachOrganization runs job with each organization in the context, the way
+// a request would have it. A failing organization does not stop the others.
+func (h *Handler) forEachOrganization(job worker.Job) worker.Job {
+	return func(ctx context.Context) error {
+		orgs, err := h.orgRepo.ListOrganizations(ctx)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, org := range orgs {
+			if err := job(auth.WithOrganization(ctx, org.OrganizationID)); err != nil {
+				errs = append(errs, fmt.Errorf("organization %s: %w", org.OrganizationID, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// CloseStreams ends long-lived event streams so that a graceful shutdown does
+// not have to wait for them.
+func (h *Handler) CloseStreams() {
+	h.eventStreamHandler.Close()
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = withRequestID(w, r)
+	if !h.limitAddress(w, r) {
+		return
+	}
+	r, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if !h.rateLimit(w, r) {
+		return
+	}
 	h.mux.ServeHTTP(w, r)
 }
 
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/config"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+	"github.com/pavel/avitotech_previewer/internal/service"
+)
+
+const maxWebhookBodySize = 5 << 20
+
+type IntegrationHandler struct {
+	*BaseHandler
+	integrationService *service.IntegrationService
+	orgRepo            *repository.OrganizationRepository
+	cfg                config.IntegrationsConfig
+}
+
+func NewIntegrationHandler(integrationService *service.IntegrationService, orgRepo *repository.OrganizationRepository, cfg config.IntegrationsConfig) *IntegrationHandler {
+	return &IntegrationHandler{
+		BaseHandler:        &BaseHandler{},
+		integrationService: integrationService,
+		orgRepo:            orgRepo,
+		cfg:                cfg,
+	}
+}
+
+// webhookOrganization binds an inbound webhook to the organization in its URL
+// and returns the secret the provider must have signed it with. Only the
+// default organization, which is also served without one in the URL, falls
+// back to the secrets from the config.
+func (h *IntegrationHandler) webhookOrganization(w http.ResponseWriter, r *http.Request, provider string) (*http.Request, string, bool) {
+	organizationID := r.PathValue("organization_id")
+	if organizationID == "" {
+		organizationID = domain.DefaultOrganizationID
+	}
+
+	org, err := h.orgRepo.GetOrganization(r.Context(), organizationID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "organization not found", "NOT_FOUND")
+			return nil, "", false
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return nil, "", false
+	}
+
+	secret := org.GitHubWebhookSecret
+	if provider == domain.ProviderGitLab {
+		secret = org.GitLabWebhookToken
+	}
+	if secret == "" && organizationID == domain.DefaultOrganizationID {
+		secret = h.cfg.GitHubWebhookSecret
+		if provider == domain.ProviderGitLab {
+			secret = h.cfg.GitLabWebhookToken
+		}
+	}
+	if secret == "" {
+		h.writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("%s webhook secret is not configured for organization %s", provider, organizationID), "WEBHOOK_DISABLED")
+		return nil, "", false
+	}
+
+	return r.WithContext(auth.WithOrganization(r.Context(), organizationID)), secret, true
+}
+
+func (h *IntegrationHandler) GitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	r, secret, ok := h.webhookOrganization(w, r, domain.ProviderGitHub)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if !service.VerifyGitHubSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		h.writeError(w, http.StatusUnauthorized, "invalid webhook signature", "INVALID_SIGNATURE")
+		return
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	switch eventType {
+	case "ping":
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+		return
+	case "pull_request":
+	default:
+		h.writeJSON(w, http.StatusOK, service.WebhookResult{
+			Status: service.WebhookStatusIgnored,
+			Event:  eventType,
+			Reason: "event type is not handled",
+		})
+		return
+	}
+
+	var event service.GitHubPullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid webhook payload", "INVALID_REQUEST")
+		return
+	}
+
+	if event.Repository.FullName == "" || event.PullRequest.Number == 0 {
+		h.writeError(w, http.StatusBadRequest, "repository and pull_request are required", "INVALID_REQUEST")
+		return
+	}
+
+	result, err := h.integrationService.HandleGitHubPullRequest(webhookContext(r, domain.ProviderGitHub), &event)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+func (h *IntegrationHandler) GitLabWebhook(w http.ResponseWriter, r *http.Request) {
+	r, token, ok := h.webhookOrganization(w, r, domain.ProviderGitLab)
+	if !ok {
+		return
+	}
+
+	if !service.VerifyGitLabToken(token, r.Header.Get("X-Gitlab-Token")) {
+		h.writeError(w, http.StatusUnauthorized, "invalid webhook token", "INVALID_SIGNATURE")
+		return
+	}
+
+	eventType := r.Header.Get("X-Gitlab-Event")
+	if eventType != "Merge Request Hook" {
+		h.writeJSON(w, http.StatusOK, service.WebhookResult{
+			Status: service.WebhookStatusIgnored,
+			Event:  eventType,
+			Reason: "event type is not handled",
+		})
+		return
+	}
+
+	var event service.GitLabMergeRequestEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBodySize)).Decode(&event); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid webhook payload", "INVALID_REQUEST")
+		return
+	}
+
+	if event.Project.PathWithNamespace == "" || event.ObjectAttributes.IID == 0 {
+		h.writeError(w, http.StatusBadRequest, "project and object_attributes are required", "INVALID_REQUEST")
+		return
+	}
+
+	result, err := h.integrationService.HandleGitLabMergeRequest(webhookContext(r, domain.ProviderGitLab), &event)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// webhookContext attributes changes made by a verified webhook to its
+// provider in the audit log.
+func webhookContext(r *http.Request, provider string) context.Context {
+	return auth.WithIdentity(r.Context(), &domain.Identity{Method: domain.AuthMethodWebhook, Subject: provider})
+}
+
+func (h *IntegrationHandler) MapUser(w http.ResponseWriter, r *http.Request) {
+	var identity domain.ExternalIdentity
+	if err := json.NewDecoder(r.Body).Decode(&identity); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if identity.Provider == "" || identity.ExternalLogin == "" || identity.UserID == "" {
+		h.writeError(w, http.StatusBadRequest, "provider, external_login and user_id are required", "MISSING_PARAMETER")
+		return
+	}
+
+	if err := h.integrationService.MapUser(r.Context(), &identity); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "user not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"mapping": identity,
+	})
+}
+
+func (h *IntegrationHandler) UnmapUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Provider      string `json:"provider"`
+		ExternalLogin string `json:"external_login"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.integrationService.UnmapUser(r.Context(), request.Provider, request.ExternalLogin); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "mapping not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"provider":       request.Provider,
+		"external_login": request.ExternalLogin,
+		"deleted":        true,
+	})
+}
+
+func (h *IntegrationHandler) ListMappings(w http.ResponseWriter, r *http.Request) {
+	provider := r.URL.Query().Get("provider")
+
+	mappings, err := h.integrationService.ListMappings(r.Context(), provider)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"mappings": mappings,
+	})
+}
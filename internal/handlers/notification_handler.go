@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/service"
+)
+
+type NotificationHandler struct {
+	*BaseHandler
+	chatNotifier *service.ChatNotifier
+}
+
+func NewNotificationHandler(chatNotifier *service.ChatNotifier) *NotificationHandler {
+	return &NotificationHandler{
+		BaseHandler:  &BaseHandler{},
+		chatNotifier: chatNotifier,
+	}
+}
+
+func (h *NotificationHandler) SetChatSettings(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		TeamName           string `json:"team_name"`
+		WebhookURL         string `json:"webhook_url"`
+		Channel            string `json:"channel,omitempty"`
+		AssignedTemplate   string `json:"assigned_template,omitempty"`
+		ReassignedTemplate string `json:"reassigned_template,omitempty"`
+		IsEnabled          *bool  `json:"is_enabled,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.TeamName == "" || request.WebhookURL == "" {
+		h.writeError(w, http.StatusBadRequest, "team_name and webhook_url are required", "MISSING_PARAMETER")
+		return
+	}
+
+	settings := &domain.TeamChatSettings{
+		TeamName:           request.TeamName,
+		WebhookURL:         request.WebhookURL,
+		Channel:            request.Channel,
+		AssignedTemplate:   request.AssignedTemplate,
+		ReassignedTemplate: request.ReassignedTemplate,
+		IsEnabled:          request.IsEnabled == nil || *request.IsEnabled,
+	}
+
+	if err := h.chatNotifier.SetTeamSettings(r.Context(), settings); err != nil {
+		switch {
+		case domain.IsDomainError(err, "NOT_FOUND"):
+			h.writeError(w, http.StatusNotFound, "team not found", "NOT_FOUND")
+		case domain.IsDomainError(err, "INVALID_URL"), domain.IsDomainError(err, "INVALID_TEMPLATE"):
+			domainErr := err.(*domain.Error)
+			h.writeError(w, http.StatusBadRequest, domainErr.Message, domainErr.Code)
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"chat_settings": settings,
+	})
+}
+
+func (h *NotificationHandler) GetChatSettings(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		h.writeError(w, http.StatusBadRequest, "team_name parameter is required", "MISSING_PARAMETER")
+		return
+	}
+
+	settings, err := h.chatNotifier.GetTeamSettings(r.Context(), teamName)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, err.(*domain.Error).Message, "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"chat_settings": settings,
+	})
+}
+
+func (h *NotificationHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		EventType     string `json:"event_type"`
+		PullRequestID string `json:"pull_request_id"`
+		OldReviewerID string `json:"old_reviewer_id,omitempty"`
+		NewReviewerID string `json:"new_reviewer_id,omitempty"`
+		Template      string `json:"template,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.EventType == "" || request.PullRequestID == "" {
+		h.writeError(w, http.StatusBadRequest, "event_type and pull_request_id are required", "MISSING_PARAMETER")
+		return
+	}
+
+	message, err := h.chatNotifier.Preview(r.Context(), service.ChatPreviewRequest{
+		EventType:     request.EventType,
+		PullRequestID: request.PullRequestID,
+		OldReviewerID: request.OldReviewerID,
+		NewReviewerID: request.NewReviewerID,
+		Template:      request.Template,
+	})
+	if err != nil {
+		switch {
+		case domain.IsDomainError(err, "NOT_FOUND"):
+			h.writeError(w, http.StatusNotFound, err.(*domain.Error).Message, "NOT_FOUND")
+		case domain.IsDomainError(err, "INVALID_EVENT"), domain.IsDomainError(err, "INVALID_TEMPLATE"),
+			domain.IsDomainError(err, "MISSING_PARAMETER"):
+			domainErr := err.(*domain.Error)
+			h.writeError(w, http.StatusBadRequest, domainErr.Message, domainErr.Code)
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"sent":    false,
+	})
+}
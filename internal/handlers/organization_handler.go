@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+// Organization ids travel in a header and a query parameter, so they are kept
+// to characters that need no escaping.
+const maxOrganizationIDLength = 64
+
+type OrganizationHandler struct {
+	*BaseHandler
+	orgRepo *repository.OrganizationRepository
+}
+
+func NewOrganizationHandler(orgRepo *repository.OrganizationRepository) *OrganizationHandler {
+	return &OrganizationHandler{
+		BaseHandler: &BaseHandler{},
+		orgRepo:     orgRepo,
+	}
+}
+
+func (h *OrganizationHandler) AddOrganization(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnbound(w, r) {
+		return
+	}
+
+	var request struct {
+		OrganizationID      string `json:"organization_id"`
+		Name                string `json:"name"`
+		GitHubWebhookSecret string `json:"github_webhook_secret,omitempty"`
+		GitLabWebhookToken  string `json:"gitlab_webhook_token,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	org := domain.Organization{
+		OrganizationID:      request.OrganizationID,
+		Name:                request.Name,
+		GitHubWebhookSecret: request.GitHubWebhookSecret,
+		GitLabWebhookToken:  request.GitLabWebhookToken,
+	}
+
+	if org.OrganizationID == "" {
+		h.writeError(w, http.StatusBadRequest, "organization_id is required", "MISSING_PARAMETER")
+		return
+	}
+	if !isValidOrganizationID(org.OrganizationID) {
+		h.writeError(w, http.StatusBadRequest, "organization_id may only contain lowercase letters, digits, - and _", "INVALID_REQUEST")
+		return
+	}
+	if org.Name == "" {
+		org.Name = org.OrganizationID
+	}
+
+	if err := h.orgRepo.CreateOrganization(r.Context(), &org); err != nil {
+		if domain.IsDomainError(err, "ORGANIZATION_EXISTS") {
+			h.writeError(w, http.StatusConflict, "organization already exists", "ORGANIZATION_EXISTS")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"organization": org,
+	})
+}
+
+func (h *OrganizationHandler) SetWebhookSecrets(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnbound(w, r) {
+		return
+	}
+
+	var request struct {
+		OrganizationID      string `json:"organization_id"`
+		GitHubWebhookSecret string `json:"github_webhook_secret"`
+		GitLabWebhookToken  string `json:"gitlab_webhook_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.OrganizationID == "" {
+		h.writeError(w, http.StatusBadRequest, "organization_id is required", "MISSING_PARAMETER")
+		return
+	}
+
+	err := h.orgRepo.SetWebhookSecrets(r.Context(), request.OrganizationID, request.GitHubWebhookSecret, request.GitLabWebhookToken)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "organization not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"organization_id":           request.OrganizationID,
+		"github_webhook_secret_set": request.GitHubWebhookSecret != "",
+		"gitlab_webhook_token_set":  request.GitLabWebhookToken != "",
+	})
+}
+
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnbound(w, r) {
+		return
+	}
+
+	orgs, err := h.orgRepo.ListOrganizations(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"organizations": orgs,
+	})
+}
+
+// requireUnbound keeps credentials of one organization from seeing or
+// creating the others.
+func (h *OrganizationHandler) requireUnbound(w http.ResponseWriter, r *http.Request) bool {
+	if identity := auth.IdentityFromContext(r.Context()); identity == nil || identity.OrganizationID != "" {
+		h.writeError(w, http.StatusForbidden, "only credentials not bound to an organization may manage organizations", "FORBIDDEN")
+		return false
+	}
+	return true
+}
+
+func isValidOrganizationID(organizationID string) bool {
+	if len(organizationID) > maxOrganizationIDLength {
+		return false
+	}
+	for _, c := range organizationID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
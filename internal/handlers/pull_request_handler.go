@@ -4,19 +4,24 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/pavel/avitotech_previewer/internal/auth"
 	"github.com/pavel/avitotech_previewer/internal/domain"
 	"github.com/pavel/avitotech_previewer/internal/service"
 )
 
 type PullRequestHandler struct {
 	*BaseHandler
-	prService *service.PullRequestService
+	prService    *service.PullRequestService
+	staleService *service.StalePRService
+	policy       *service.AccessPolicy
 }
 
-func NewPullRequestHandler(prService *service.PullRequestService) *PullRequestHandler {
+func NewPullRequestHandler(prService *service.PullRequestService, staleService *service.StalePRService, policy *service.AccessPolicy) *PullRequestHandler {
 	return &PullRequestHandler{
-		BaseHandler: &BaseHandler{},
-		prService:   prService,
+		BaseHandler:  &BaseHandler{},
+		prService:    prService,
+		staleService: staleService,
+		policy:       policy,
 	}
 }
 
@@ -25,6 +30,7 @@ func (h *PullRequestHandler) CreatePR(w http.ResponseWriter, r *http.Request) {
 		PullRequestID   string `json:"pull_request_id"`
 		PullRequestName string `json:"pull_request_name"`
 		AuthorID        string `json:"author_id"`
+		Draft           bool   `json:"draft,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -32,33 +38,42 @@ func (h *PullRequestHandler) CreatePR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.policy.CanCreatePR(r.Context(), auth.IdentityFromContext(r.Context()), request.AuthorID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
 	pr := &domain.PullRequest{
 		PullRequestID:   request.PullRequestID,
 		PullRequestName: request.PullRequestName,
 		AuthorID:        request.AuthorID,
 	}
+	if request.Draft {
+		pr.Status = domain.PRStatusDraft
+	}
 
-	createdPR, err := h.prService.CreatePR(r.Context(), pr)
+	result, err := h.prService.CreatePR(r.Context(), pr)
 	if err != nil {
 		switch {
 		case domain.IsDomainError(err, "PR_EXISTS"):
 			h.writeError(w, http.StatusConflict, "PR id already exists", "PR_EXISTS")
 		case domain.IsDomainError(err, "NOT_FOUND"):
 			h.writeError(w, http.StatusNotFound, "author/team not found", "NOT_FOUND")
+		case domain.IsDomainError(err, "CAPACITY_EXCEEDED"):
+			h.writeError(w, http.StatusConflict, "all reviewer candidates are at capacity", "CAPACITY_EXCEEDED")
 		default:
 			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
 		}
 		return
 	}
 
-	h.writeJSON(w, http.StatusCreated, map[string]interface{}{
-		"pr": createdPR,
-	})
+	h.writeJSON(w, http.StatusCreated, result)
 }
 
 func (h *PullRequestHandler) MergePR(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		PullRequestID string `json:"pull_request_id"`
+		AdminOverride bool   `json:"admin_override,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -66,13 +81,27 @@ func (h *PullRequestHandler) MergePR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, err := h.prService.MergePR(r.Context(), request.PullRequestID)
+	if request.AdminOverride {
+		if err := h.policy.CanOverrideMerge(r.Context(), auth.IdentityFromContext(r.Context())); err != nil {
+			h.writeAccessError(w, err)
+			return
+		}
+	}
+
+	pr, err := h.prService.MergePR(r.Context(), request.PullRequestID, request.AdminOverride)
 	if err != nil {
-		if domain.IsDomainError(err, "NOT_FOUND") {
+		switch {
+		case domain.IsDomainError(err, "NOT_FOUND"):
 			h.writeError(w, http.StatusNotFound, "PR not found", "NOT_FOUND")
-			return
+		case domain.IsDomainError(err, "PR_DRAFT"):
+			h.writeError(w, http.StatusConflict, "cannot merge draft PR", "PR_DRAFT")
+		case domain.IsDomainError(err, "PR_CLOSED"):
+			h.writeError(w, http.StatusConflict, "cannot merge closed PR", "PR_CLOSED")
+		case domain.IsDomainError(err, "MERGE_BLOCKED"):
+			h.writeErrorWithDetails(w, http.StatusConflict, "PR does not satisfy the team approval policy", "MERGE_BLOCKED", err.(*domain.Error).Details)
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
 		}
-		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
 		return
 	}
 
@@ -92,17 +121,26 @@ func (h *PullRequestHandler) ReassignPR(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	newReviewerID, err := h.prService.ReassignReviewer(r.Context(), request.PullRequestID, request.OldUserID)
+	if err := h.policy.CanReassign(r.Context(), auth.IdentityFromContext(r.Context()), request.PullRequestID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	reassigned, err := h.prService.ReassignReviewer(r.Context(), request.PullRequestID, request.OldUserID, domain.AssignmentReasonManual)
 	if err != nil {
 		switch {
 		case domain.IsDomainError(err, "NOT_FOUND"):
 			h.writeError(w, http.StatusNotFound, "PR or user not found", "NOT_FOUND")
 		case domain.IsDomainError(err, "PR_MERGED"):
 			h.writeError(w, http.StatusConflict, "cannot reassign on merged PR", "PR_MERGED")
+		case domain.IsDomainError(err, "PR_CLOSED"):
+			h.writeError(w, http.StatusConflict, "cannot reassign on closed PR", "PR_CLOSED")
 		case domain.IsDomainError(err, "NOT_ASSIGNED"):
 			h.writeError(w, http.StatusConflict, "reviewer is not assigned to this PR", "NOT_ASSIGNED")
 		case domain.IsDomainError(err, "NO_CANDIDATE"):
 			h.writeError(w, http.StatusConflict, "no active replacement candidate in team", "NO_CANDIDATE")
+		case domain.IsDomainError(err, "CAPACITY_EXCEEDED"):
+			h.writeError(w, http.StatusConflict, "all replacement candidates are at capacity", "CAPACITY_EXCEEDED")
 		default:
 			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
 		}
@@ -115,8 +153,215 @@ func (h *PullRequestHandler) ReassignPR(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"pr":          pr,
-		"replaced_by": newReviewerID,
+		"replaced_by": reassigned.NewReviewerID,
+	}
+	if reassigned.BorrowedFrom != "" {
+		response["borrowed_from"] = reassigned.BorrowedFrom
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+func (h *PullRequestHandler) SubmitReview(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PullRequestID string `json:"pull_request_id"`
+		ReviewerID    string `json:"reviewer_id"`
+		State         string `json:"state"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.ReviewerID == "" {
+		request.ReviewerID = callerUserID(r)
+	}
+
+	if err := h.policy.CanReview(r.Context(), auth.IdentityFromContext(r.Context()), request.ReviewerID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	pr, err := h.prService.SubmitReview(r.Context(), request.PullRequestID, request.ReviewerID, request.State)
+	if err != nil {
+		switch {
+		case domain.IsDomainError(err, "INVALID_STATE"):
+			h.writeError(w, http.StatusBadRequest, "state must be APPROVED, CHANGES_REQUESTED or COMMENTED", "INVALID_STATE")
+		case domain.IsDomainError(err, "NOT_FOUND"):
+			h.writeError(w, http.StatusNotFound, "PR not found", "NOT_FOUND")
+		case domain.IsDomainError(err, "PR_MERGED"):
+			h.writeError(w, http.StatusConflict, "cannot review merged PR", "PR_MERGED")
+		case domain.IsDomainError(err, "PR_CLOSED"):
+			h.writeError(w, http.StatusConflict, "cannot review closed PR", "PR_CLOSED")
+		case domain.IsDomainError(err, "NOT_ASSIGNED"):
+			h.writeError(w, http.StatusConflict, "reviewer is not assigned to this PR", "NOT_ASSIGNED")
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *PullRequestHandler) GetPR(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		h.writeError(w, http.StatusBadRequest, "pull_request_id parameter is required", "MISSING_PARAMETER")
+		return
+	}
+
+	pr, err := h.prService.GetPR(r.Context(), prID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "PR not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *PullRequestHandler) ClosePR(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PullRequestID string `json:"pull_request_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.policy.CanChangePRStatus(r.Context(), auth.IdentityFromContext(r.Context()), request.PullRequestID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	pr, err := h.prService.ClosePR(r.Context(), request.PullRequestID, domain.CloseReasonManual)
+	if err != nil {
+		switch {
+		case domain.IsDomainError(err, "NOT_FOUND"):
+			h.writeError(w, http.StatusNotFound, "PR not found", "NOT_FOUND")
+		case domain.IsDomainError(err, "PR_MERGED"):
+			h.writeError(w, http.StatusConflict, "cannot close merged PR", "PR_MERGED")
+		case domain.IsDomainError(err, "INVALID_TRANSITION"):
+			h.writeError(w, http.StatusConflict, "PR status changed concurrently", "INVALID_TRANSITION")
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *PullRequestHandler) ReopenPR(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PullRequestID string `json:"pull_request_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.policy.CanChangePRStatus(r.Context(), auth.IdentityFromContext(r.Context()), request.PullRequestID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	result, err := h.prService.ReopenPR(r.Context(), request.PullRequestID)
+	if err != nil {
+		h.writeOpenPRError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+func (h *PullRequestHandler) MarkReady(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PullRequestID string `json:"pull_request_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.policy.CanChangePRStatus(r.Context(), auth.IdentityFromContext(r.Context()), request.PullRequestID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	result, err := h.prService.MarkReady(r.Context(), request.PullRequestID)
+	if err != nil {
+		h.writeOpenPRError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+func (h *PullRequestHandler) writeOpenPRError(w http.ResponseWriter, err error) {
+	switch {
+	case domain.IsDomainError(err, "NOT_FOUND"):
+		h.writeError(w, http.StatusNotFound, "PR or author not found", "NOT_FOUND")
+	case domain.IsDomainError(err, "PR_NOT_DRAFT"):
+		h.writeError(w, http.StatusConflict, "PR is not a draft", "PR_NOT_DRAFT")
+	case domain.IsDomainError(err, "PR_NOT_CLOSED"):
+		h.writeError(w, http.StatusConflict, "only closed PR can be reopened", "PR_NOT_CLOSED")
+	case domain.IsDomainError(err, "CAPACITY_EXCEEDED"):
+		h.writeError(w, http.StatusConflict, "all reviewer candidates are at capacity", "CAPACITY_EXCEEDED")
+	case domain.IsDomainError(err, "INVALID_TRANSITION"):
+		h.writeError(w, http.StatusConflict, "PR status changed concurrently", "INVALID_TRANSITION")
+	default:
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+	}
+}
+
+func (h *PullRequestHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		h.writeError(w, http.StatusBadRequest, "pull_request_id parameter is required", "MISSING_PARAMETER")
+		return
+	}
+
+	history, err := h.prService.GetAssignmentHistory(r.Context(), prID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "PR not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pull_request_id": prID,
+		"history":         history,
+	})
+}
+
+func (h *PullRequestHandler) StaleDryRun(w http.ResponseWriter, r *http.Request) {
+	prs, err := h.staleService.DryRun(r.Context(), r.URL.Query().Get("team_name"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pull_requests": prs,
 	})
 }
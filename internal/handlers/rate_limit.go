@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+)
+
+// limitAddress runs before authentication and charges every request to its
+// client IP, so that requests with bad or missing credentials are throttled
+// before any key or organization is looked up.
+func (h *Handler) limitAddress(w http.ResponseWriter, r *http.Request) bool {
+	allowed, wait := h.rateLimiter.AllowAddress(h.clientIP(r))
+	if allowed {
+		return true
+	}
+	h.writeRateLimited(w, wait)
+	return false
+}
+
+// rateLimit applies the limit of the matched route to the authenticated
+// caller and answers 429 once the caller's bucket is empty. Unknown routes
+// are left to the mux.
+func (h *Handler) rateLimit(w http.ResponseWriter, r *http.Request) bool {
+	_, pattern := h.mux.Handler(r)
+	if _, ok := h.routeScopes[pattern]; !ok {
+		return true
+	}
+
+	allowed, wait := h.rateLimiter.Allow(pattern, h.rateLimitKey(r))
+	if allowed {
+		return true
+	}
+	h.writeRateLimited(w, wait)
+	return false
+}
+
+func (h *Handler) writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	h.writeError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded, retry in %d s", retryAfter), "RATE_LIMITED")
+}
+
+// rateLimitKey identifies the caller: API keys and personal tokens by their
+// credential, so that callers sharing a NAT do not share a limit, and
+// everyone else, including the bootstrap key, by client IP.
+func (h *Handler) rateLimitKey(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		switch {
+		case identity.APIKeyID != 0:
+			return fmt.Sprintf("key:%d", identity.APIKeyID)
+		case identity.UserID != "":
+			return fmt.Sprintf("user:%s:%s", auth.OrganizationFromContext(r.Context()), identity.UserID)
+		}
+	}
+
+	return "ip:" + h.clientIP(r)
+}
+
+// clientIP is taken from the connection, since forwarding headers can be set
+// by the client. Only when the connection comes from a trusted proxy is
+// X-Forwarded-For read, from the right: the first address that is not a
+// trusted proxy itself is the client.
+func (h *Handler) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !h.trustedProxy(host) {
+		return host
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		host = hop
+		if !h.trustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func (h *Handler) trustedProxy(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,21 +2,26 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
+	"github.com/pavel/avitotech_previewer/internal/auth"
 	"github.com/pavel/avitotech_previewer/internal/domain"
 	"github.com/pavel/avitotech_previewer/internal/repository"
+	"github.com/pavel/avitotech_previewer/internal/service"
 )
 
 type TeamHandler struct {
 	*BaseHandler
 	teamRepo *repository.TeamRepository
+	policy   *service.AccessPolicy
 }
 
-func NewTeamHandler(teamRepo *repository.TeamRepository) *TeamHandler {
+func NewTeamHandler(teamRepo *repository.TeamRepository, policy *service.AccessPolicy) *TeamHandler {
 	return &TeamHandler{
 		BaseHandler: &BaseHandler{},
 		teamRepo:    teamRepo,
+		policy:      policy,
 	}
 }
 
@@ -32,6 +37,57 @@ func (h *TeamHandler) AddTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.policy.CanCreateTeam(r.Context(), auth.IdentityFromContext(r.Context())); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	if team.ReviewerStrategy != "" && !domain.IsValidReviewerStrategy(team.ReviewerStrategy) {
+		h.writeError(w, http.StatusBadRequest, "unknown reviewer_strategy", "INVALID_REQUEST")
+		return
+	}
+
+	if team.ReviewersCount != 0 && !domain.IsValidReviewersCount(team.ReviewersCount) {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("reviewers_count must be between %d and %d", domain.MinReviewersCount, domain.MaxReviewersCount), "INVALID_REQUEST")
+		return
+	}
+
+	if team.OverloadPolicy != "" && !domain.IsValidOverloadPolicy(team.OverloadPolicy) {
+		h.writeError(w, http.StatusBadRequest, "overload_policy must be reject or queue", "INVALID_REQUEST")
+		return
+	}
+
+	if !domain.IsValidRequiredApprovals(team.RequiredApprovals) {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("required_approvals must be between 0 and %d", domain.MaxReviewersCount), "INVALID_REQUEST")
+		return
+	}
+
+	if team.DefaultMaxOpenReviews < 0 {
+		h.writeError(w, http.StatusBadRequest, "default_max_open_reviews must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	if team.SLAReminderHours < 0 || team.SLAEscalationHours < 0 {
+		h.writeError(w, http.StatusBadRequest, "sla_reminder_hours and sla_escalation_hours must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	if team.StaleAfterDays < 0 || team.StaleCloseAfterDays < 0 {
+		h.writeError(w, http.StatusBadRequest, "stale_after_days and stale_close_after_days must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	for _, member := range team.Members {
+		if member.MaxOpenReviews < 0 {
+			h.writeError(w, http.StatusBadRequest, "max_open_reviews must not be negative", "INVALID_REQUEST")
+			return
+		}
+		if member.Role != "" && !domain.IsValidRole(member.Role) {
+			h.writeError(w, http.StatusBadRequest, "role must be admin, team_lead or member", "INVALID_REQUEST")
+			return
+		}
+	}
+
 	if err := h.teamRepo.CreateTeam(r.Context(), &team); err != nil {
 		if domain.IsDomainError(err, "TEAM_EXISTS") {
 			h.writeError(w, http.StatusBadRequest, "team_name already exists", "TEAM_EXISTS")
@@ -65,3 +121,126 @@ func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
 
 	h.writeJSON(w, http.StatusOK, team)
 }
+
+func (h *TeamHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		TeamName string `json:"team_name"`
+		domain.TeamSettingsUpdate
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.TeamName == "" {
+		h.writeError(w, http.StatusBadRequest, "team_name is required", "MISSING_PARAMETER")
+		return
+	}
+
+	if err := h.policy.CanManageTeam(r.Context(), auth.IdentityFromContext(r.Context()), request.TeamName); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	if request.ReviewerStrategy != nil && !domain.IsValidReviewerStrategy(*request.ReviewerStrategy) {
+		h.writeError(w, http.StatusBadRequest, "unknown reviewer_strategy", "INVALID_REQUEST")
+		return
+	}
+
+	if request.ReviewersCount != nil && !domain.IsValidReviewersCount(*request.ReviewersCount) {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("reviewers_count must be between %d and %d", domain.MinReviewersCount, domain.MaxReviewersCount), "INVALID_REQUEST")
+		return
+	}
+
+	if request.OverloadPolicy != nil && !domain.IsValidOverloadPolicy(*request.OverloadPolicy) {
+		h.writeError(w, http.StatusBadRequest, "overload_policy must be reject or queue", "INVALID_REQUEST")
+		return
+	}
+
+	if request.RequiredApprovals != nil && !domain.IsValidRequiredApprovals(*request.RequiredApprovals) {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("required_approvals must be between 0 and %d", domain.MaxReviewersCount), "INVALID_REQUEST")
+		return
+	}
+
+	if request.DefaultMaxOpenReviews != nil && *request.DefaultMaxOpenReviews < 0 {
+		h.writeError(w, http.StatusBadRequest, "default_max_open_reviews must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	if (request.SLAReminderHours != nil && *request.SLAReminderHours < 0) ||
+		(request.SLAEscalationHours != nil && *request.SLAEscalationHours < 0) {
+		h.writeError(w, http.StatusBadRequest, "sla_reminder_hours and sla_escalation_hours must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	if (request.StaleAfterDays != nil && *request.StaleAfterDays < 0) ||
+		(request.StaleCloseAfterDays != nil && *request.StaleCloseAfterDays < 0) {
+		h.writeError(w, http.StatusBadRequest, "stale_after_days and stale_close_after_days must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.teamRepo.UpdateTeamSettings(r.Context(), request.TeamName, request.TeamSettingsUpdate); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "team not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	team, err := h.teamRepo.GetTeam(r.Context(), request.TeamName)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"team": team,
+	})
+}
+
+func (h *TeamHandler) SetFallbackTeams(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		TeamName      string   `json:"team_name"`
+		FallbackTeams []string `json:"fallback_teams"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.TeamName == "" {
+		h.writeError(w, http.StatusBadRequest, "team_name is required", "MISSING_PARAMETER")
+		return
+	}
+
+	if err := h.policy.CanManageTeam(r.Context(), auth.IdentityFromContext(r.Context()), request.TeamName); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, fallbackTeam := range request.FallbackTeams {
+		if fallbackTeam == request.TeamName || seen[fallbackTeam] {
+			h.writeError(w, http.StatusBadRequest, "fallback_teams must be unique and differ from team_name", "INVALID_REQUEST")
+			return
+		}
+		seen[fallbackTeam] = true
+	}
+
+	if err := h.teamRepo.SetFallbackTeams(r.Context(), request.TeamName, request.FallbackTeams); err != nil {
+		if domainErr, ok := err.(*domain.Error); ok && domainErr.Code == "NOT_FOUND" {
+			h.writeError(w, http.StatusNotFound, domainErr.Message, "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"team_name":      request.TeamName,
+		"fallback_teams": request.FallbackTeams,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/pavel/avitotech_previewer/internal/auth"
 	"github.com/pavel/avitotech_previewer/internal/domain"
 	"github.com/pavel/avitotech_previewer/internal/repository"
 	"github.com/pavel/avitotech_previewer/internal/service"
@@ -11,15 +12,19 @@ import (
 
 type UserHandler struct {
 	*BaseHandler
-	userRepo  *repository.UserRepository
-	prService *service.PullRequestService
+	userRepo      *repository.UserRepository
+	prService     *service.PullRequestService
+	emailNotifier *service.EmailNotifier
+	policy        *service.AccessPolicy
 }
 
-func NewUserHandler(userRepo *repository.UserRepository, prService *service.PullRequestService) *UserHandler {
+func NewUserHandler(userRepo *repository.UserRepository, prService *service.PullRequestService, emailNotifier *service.EmailNotifier, policy *service.AccessPolicy) *UserHandler {
 	return &UserHandler{
-		BaseHandler: &BaseHandler{},
-		userRepo:    userRepo,
-		prService:   prService,
+		BaseHandler:   &BaseHandler{},
+		userRepo:      userRepo,
+		prService:     prService,
+		emailNotifier: emailNotifier,
+		policy:        policy,
 	}
 }
 
@@ -34,6 +39,11 @@ func (h *UserHandler) SetUserActive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.policy.CanManageUser(r.Context(), auth.IdentityFromContext(r.Context()), request.UserID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
 	user, err := h.userRepo.UpdateUserActive(r.Context(), request.UserID, request.IsActive)
 	if err != nil {
 		if domain.IsDomainError(err, "NOT_FOUND") {
@@ -49,8 +59,140 @@ func (h *UserHandler) SetUserActive(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *UserHandler) SetMaxOpenReviews(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		UserID         string `json:"user_id"`
+		MaxOpenReviews int    `json:"max_open_reviews"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.MaxOpenReviews < 0 {
+		h.writeError(w, http.StatusBadRequest, "max_open_reviews must not be negative", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.policy.CanEditUser(r.Context(), auth.IdentityFromContext(r.Context()), request.UserID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	if err := h.userRepo.UpdateUserMaxOpenReviews(r.Context(), request.UserID, request.MaxOpenReviews); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "user not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":          request.UserID,
+		"max_open_reviews": request.MaxOpenReviews,
+	})
+}
+
+func (h *UserHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		UserID string `json:"user_id"`
+		Role   string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.UserID == "" {
+		h.writeError(w, http.StatusBadRequest, "user_id is required", "MISSING_PARAMETER")
+		return
+	}
+
+	if !domain.IsValidRole(request.Role) {
+		h.writeError(w, http.StatusBadRequest, "role must be admin, team_lead or member", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.policy.CanSetRole(r.Context(), auth.IdentityFromContext(r.Context())); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	user, err := h.userRepo.UpdateUserRole(r.Context(), request.UserID, request.Role)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "user not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user": user,
+	})
+}
+
+func (h *UserHandler) SetEmailSettings(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		UserID             string `json:"user_id"`
+		Email              string `json:"email"`
+		EmailNotifications string `json:"email_notifications"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.UserID == "" {
+		h.writeError(w, http.StatusBadRequest, "user_id is required", "MISSING_PARAMETER")
+		return
+	}
+
+	if err := h.policy.CanEditUser(r.Context(), auth.IdentityFromContext(r.Context()), request.UserID); err != nil {
+		h.writeAccessError(w, err)
+		return
+	}
+
+	settings := &domain.EmailSettings{
+		UserID:        request.UserID,
+		Email:         request.Email,
+		Notifications: request.EmailNotifications,
+	}
+	if settings.Notifications == "" {
+		settings.Notifications = domain.EmailNotificationsImmediate
+		if settings.Email == "" {
+			settings.Notifications = domain.EmailNotificationsOff
+		}
+	}
+
+	if err := h.emailNotifier.SetEmailSettings(r.Context(), settings); err != nil {
+		switch {
+		case domain.IsDomainError(err, "NOT_FOUND"):
+			h.writeError(w, http.StatusNotFound, "user not found", "NOT_FOUND")
+		case domain.IsDomainError(err, "INVALID_MODE"), domain.IsDomainError(err, "INVALID_EMAIL"):
+			domainErr := err.(*domain.Error)
+			h.writeError(w, http.StatusBadRequest, domainErr.Message, domainErr.Code)
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"email_settings": settings,
+	})
+}
+
 func (h *UserHandler) GetUserReviews(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = callerUserID(r)
+	}
 	if userID == "" {
 		h.writeError(w, http.StatusBadRequest, "user_id parameter is required", "MISSING_PARAMETER")
 		return
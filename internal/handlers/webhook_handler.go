@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/service"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+type WebhookHandler struct {
+	*BaseHandler
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		BaseHandler:    &BaseHandler{},
+		webhookService: webhookService,
+	}
+}
+
+func (h *WebhookHandler) AddSubscription(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		URL    string   `json:"url"`
+		Secret string   `json:"secret,omitempty"`
+		Events []string `json:"events,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if request.URL == "" {
+		h.writeError(w, http.StatusBadRequest, "url is required", "MISSING_PARAMETER")
+		return
+	}
+
+	sub := &domain.WebhookSubscription{
+		URL:    request.URL,
+		Secret: request.Secret,
+		Events: request.Events,
+	}
+
+	if err := h.webhookService.AddSubscription(r.Context(), sub); err != nil {
+		switch {
+		case domain.IsDomainError(err, "INVALID_URL"):
+			h.writeError(w, http.StatusBadRequest, err.(*domain.Error).Message, "INVALID_URL")
+		case domain.IsDomainError(err, "INVALID_EVENT"):
+			domainErr := err.(*domain.Error)
+			h.writeErrorWithDetails(w, http.StatusBadRequest, domainErr.Message, "INVALID_EVENT", domainErr.Details)
+		default:
+			h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		}
+		return
+	}
+
+	// The secret is only ever returned here, so that generated secrets can be
+	// stored by the subscriber.
+	h.writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"subscription": sub,
+		"secret":       sub.Secret,
+	})
+}
+
+func (h *WebhookHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"subscriptions": subscriptions,
+	})
+}
+
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SubscriptionID int `json:"subscription_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(r.Context(), request.SubscriptionID); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "subscription not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"subscription_id": request.SubscriptionID,
+		"deleted":         true,
+	})
+}
+
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	subscriptionID := 0
+	if value := query.Get("subscription_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			h.writeError(w, http.StatusBadRequest, "subscription_id must be a positive integer", "INVALID_REQUEST")
+			return
+		}
+		subscriptionID = id
+	}
+
+	status := query.Get("status")
+	switch status {
+	case "", domain.DeliveryStatusPending, domain.DeliveryStatusDelivered, domain.DeliveryStatusFailed:
+	default:
+		h.writeError(w, http.StatusBadRequest, "status must be PENDING, DELIVERED or FAILED", "INVALID_REQUEST")
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			h.writeError(w, http.StatusBadRequest, "limit must be between 1 and 500", "INVALID_REQUEST")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(r.Context(), subscriptionID, status, limit)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+	})
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		DeliveryID int64 `json:"delivery_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	if err := h.webhookService.Redeliver(r.Context(), request.DeliveryID); err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			h.writeError(w, http.StatusNotFound, "delivery not found", "NOT_FOUND")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h.writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"delivery_id": request.DeliveryID,
+		"status":      domain.DeliveryStatusPending,
+	})
+}
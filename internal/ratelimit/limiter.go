@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/config"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// dropped; such a bucket behaves exactly like a new one.
+const sweepInterval = time.Minute
+
+// Limiter keeps one token bucket per route and caller in memory, so each
+// instance of the service enforces the limits on its own.
+type Limiter struct {
+	cfg       config.RateLimitConfig
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	route  string
+	caller string
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   config.RateLimit
+}
+
+func New(cfg config.RateLimitConfig) *Limiter {
+	return &Limiter{
+		cfg:       cfg,
+		buckets:   make(map[bucketKey]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token for the caller on the route. When the bucket is empty
+// it returns false and how long until the next token is added.
+func (l *Limiter) Allow(route, caller string) (bool, time.Duration) {
+	limit, ok := l.cfg.Routes[route]
+	if !ok {
+		limit = l.cfg.Default
+	}
+	return l.take(bucketKey{route: route, caller: caller}, limit)
+}
+
+// AllowAddress takes a token from the bucket shared by all requests from the
+// client address, whatever their route and credentials.
+func (l *Limiter) AllowAddress(address string) (bool, time.Duration) {
+	return l.take(bucketKey{caller: "ip:" + address}, l.cfg.PerAddress)
+}
+
+func (l *Limiter) take(key bucketKey, limit config.RateLimit) (bool, time.Duration) {
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - b.tokens) / limit.Rate * float64(time.Second)))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.updated = now
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type AbsenceRepository struct {
+	db *sql.DB
+}
+
+func NewAbsenceRepository(db *sql.DB) *AbsenceRepository {
+	return &AbsenceRepository{db: db}
+}
+
+func (r *AbsenceRepository) CreateAbsence(ctx context.Context, absence *domain.Absence) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO user_absences (user_id, starts_at, ends_at, reason, organization_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		absence.UserID, absence.StartsAt, absence.EndsAt, absence.Reason, orgID(ctx)).Scan(&absence.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert absence: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionUserAddAbsence, domain.AuditEntityUser, absence.UserID, nil, absence); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AbsenceRepository) GetUserAbsences(ctx context.Context, userID string) ([]domain.Absence, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, starts_at, ends_at, reason, reviews_reassigned_at
+		FROM user_absences
+		WHERE organization_id = $2 AND user_id = $1
+		ORDER BY starts_at`,
+		userID, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query absences: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAbsences(rows)
+}
+
+func (r *AbsenceRepository) GetAbsence(ctx context.Context, absenceID int) (*domain.Absence, error) {
+	var absence domain.Absence
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, starts_at, ends_at, reason, reviews_reassigned_at
+		FROM user_absences
+		WHERE organization_id = $2 AND id = $1`,
+		absenceID, orgID(ctx)).Scan(&absence.ID, &absence.UserID, &absence.StartsAt, &absence.EndsAt,
+		&absence.Reason, &absence.ReviewsReassignedAt)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "absence not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absence: %w", err)
+	}
+	return &absence, nil
+}
+
+func (r *AbsenceRepository) DeleteAbsence(ctx context.Context, absenceID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var absence domain.Absence
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM user_absences 
+		WHERE organization_id = $2 AND id = $1
+		RETURNING id, user_id, starts_at, ends_at, reason, reviews_reassigned_at`,
+		absenceID, orgID(ctx)).Scan(&absence.ID, &absence.UserID, &absence.StartsAt, &absence.EndsAt,
+		&absence.Reason, &absence.ReviewsReassignedAt)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "absence not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete absence: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionUserDeleteAbsence, domain.AuditEntityUser, absence.UserID, &absence, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AbsenceRepository) GetStartedAbsences(ctx context.Context) ([]domain.Absence, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, starts_at, ends_at, reason, reviews_reassigned_at
+		FROM user_absences
+		WHERE organization_id = $1 AND reviews_reassigned_at IS NULL
+			AND starts_at <= CURRENT_TIMESTAMP AND ends_at > CURRENT_TIMESTAMP
+		ORDER BY starts_at`,
+		orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query started absences: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAbsences(rows)
+}
+
+func (r *AbsenceRepository) MarkReviewsReassigned(ctx context.Context, absenceID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_absences 
+		SET reviews_reassigned_at = CURRENT_TIMESTAMP 
+		WHERE organization_id = $2 AND id = $1`,
+		absenceID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to mark absence processed: %w", err)
+	}
+	return nil
+}
+
+func scanAbsences(rows *sql.Rows) ([]domain.Absence, error) {
+	absences := make([]domain.Absence, 0)
+	for rows.Next() {
+		var absence domain.Absence
+		if err := rows.Scan(&absence.ID, &absence.UserID, &absence.StartsAt, &absence.EndsAt,
+			&absence.Reason, &absence.ReviewsReassignedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan absence: %w", err)
+		}
+		absences = append(absences, absence)
+	}
+	return absences, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// CreateAPIKey binds the key to the organization it is issued in. Neither the
+// key nor its hash goes into the audit log.
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey, keyHash string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	key.OrganizationID = orgID(ctx)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO api_keys (name, key_prefix, key_hash, scopes, organization_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.OrganizationID).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionAPIKeyIssue, domain.AuditEntityAPIKey, strconv.Itoa(key.ID), nil, key); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListAPIKeys returns the keys of the organization.
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, key_prefix, scopes, organization_id, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE organization_id = $1
+		ORDER BY id`,
+		orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]domain.APIKey, 0)
+	for rows.Next() {
+		var key domain.APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.OrganizationID, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetActiveAPIKey looks a key up by its hash in all organizations, since the
+// key is what tells which organization the caller belongs to. Revoked keys
+// are not found.
+func (r *APIKeyRepository) GetActiveAPIKey(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, key_prefix, scopes, organization_id, created_at, last_used_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`,
+		keyHash).Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.OrganizationID, &key.CreatedAt, &key.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "API key not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) MarkAPIKeyUsed(ctx context.Context, keyID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys 
+		SET last_used_at = CURRENT_TIMESTAMP 
+		WHERE id = $1`,
+		keyID)
+	if err != nil {
+		return fmt.Errorf("failed to update API key usage: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, keyID int) (*domain.APIKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var key domain.APIKey
+	var previouslyRevokedAt *time.Time
+	err = tx.QueryRowContext(ctx, `
+		UPDATE api_keys k 
+		SET revoked_at = COALESCE(k.revoked_at, CURRENT_TIMESTAMP) 
+		FROM api_keys old 
+		WHERE old.id = k.id AND k.id = $1 AND k.organization_id = $2
+		RETURNING k.id, k.name, k.key_prefix, k.scopes, k.organization_id, k.created_at, k.last_used_at, k.revoked_at, old.revoked_at`,
+		keyID, orgID(ctx)).Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.OrganizationID, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt, &previouslyRevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "API key not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionAPIKeyRevoke, domain.AuditEntityAPIKey, strconv.Itoa(key.ID),
+		map[string]interface{}{"revoked_at": previouslyRevokedAt},
+		map[string]interface{}{"revoked_at": key.RevokedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// insertAuditEntry records a change in the caller's transaction, so the log
+// cannot miss a committed change or show one that was rolled back. The actor
+// and request id come from the context; changes made outside a request are
+// attributed to the system.
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, action, entityType, entityID string, before, after interface{}) error {
+	beforeJSON, err := auditValue(before)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s audit entry: %w", action, err)
+	}
+	afterJSON, err := auditValue(after)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s audit entry: %w", action, err)
+	}
+
+	method, actorID, actorName := auditActor(ctx)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (request_id, actor_method, actor_id, actor_name, action, entity_type, entity_id, before, after, organization_id)
+		VALUES (NULLIF($1, ''), $2, NULLIF($3, ''), $4, $5, $6, $7, $8::jsonb, $9::jsonb, $10)`,
+		auth.RequestIDFromContext(ctx), method, actorID, actorName, action, entityType, entityID, beforeJSON, afterJSON, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+func auditValue(value interface{}) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{}, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(raw), Valid: true}, nil
+}
+
+func auditActor(ctx context.Context) (method, actorID, actorName string) {
+	identity := auth.IdentityFromContext(ctx)
+	switch {
+	case identity == nil:
+		return domain.AuthMethodSystem, "", domain.AuthMethodSystem
+	case identity.UserID != "":
+		return identity.Method, identity.UserID, identity.Subject
+	case identity.APIKeyID != 0:
+		return identity.Method, strconv.Itoa(identity.APIKeyID), identity.Subject
+	default:
+		return identity.Method, "", identity.Subject
+	}
+}
+
+// ListEntries returns entries of the organization matching the filter, newest
+// first. Actor matches either the actor id or the actor name.
+func (r *AuditRepository) ListEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	query := `
+		SELECT id, COALESCE(request_id, ''), actor_method, COALESCE(actor_id, ''), actor_name,
+			action, entity_type, entity_id, before, after, created_at
+		FROM audit_log
+		WHERE organization_id = $1`
+	args := []interface{}{orgID(ctx)}
+
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		query += fmt.Sprintf(" AND action = $%d", len(args))
+	}
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		query += fmt.Sprintf(" AND entity_type = $%d", len(args))
+	}
+	if filter.EntityID != "" {
+		args = append(args, filter.EntityID)
+		query += fmt.Sprintf(" AND entity_id = $%d", len(args))
+	}
+	if filter.RequestID != "" {
+		args = append(args, filter.RequestID)
+		query += fmt.Sprintf(" AND request_id = $%d", len(args))
+	}
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		query += fmt.Sprintf(" AND (actor_id = $%d OR actor_name = $%d)", len(args), len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if filter.BeforeID > 0 {
+		args = append(args, filter.BeforeID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]domain.AuditEntry, 0)
+	for rows.Next() {
+		var e domain.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.RequestID, &e.ActorMethod, &e.ActorID, &e.ActorName,
+			&e.Action, &e.EntityType, &e.EntityID, &before, &after, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type ChatSettingsRepository struct {
+	db *sql.DB
+}
+
+func NewChatSettingsRepository(db *sql.DB) *ChatSettingsRepository {
+	return &ChatSettingsRepository{db: db}
+}
+
+func (r *ChatSettingsRepository) UpsertTeamChatSettings(ctx context.Context, settings *domain.TeamChatSettings) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var teamExists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM teams WHERE organization_id = $2 AND team_name = $1)",
+		settings.TeamName, orgID(ctx)).Scan(&teamExists)
+	if err != nil {
+		return fmt.Errorf("failed to check team existence: %w", err)
+	}
+	if !teamExists {
+		return &domain.Error{Code: "NOT_FOUND", Message: "team not found"}
+	}
+
+	var before interface{}
+	previous := domain.TeamChatSettings{TeamName: settings.TeamName}
+	err = tx.QueryRowContext(ctx, `
+		SELECT webhook_url, channel, assigned_template, reassigned_template, is_enabled
+		FROM team_chat_settings
+		WHERE organization_id = $2 AND team_name = $1
+		FOR UPDATE`,
+		settings.TeamName, orgID(ctx)).Scan(&previous.WebhookURL, &previous.Channel,
+		&previous.AssignedTemplate, &previous.ReassignedTemplate, &previous.IsEnabled)
+	switch {
+	case err == nil:
+		before = chatSettingsAudit(&previous)
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("failed to get team chat settings: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO team_chat_settings (team_name, webhook_url, channel, assigned_template, reassigned_template, is_enabled, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (organization_id, team_name)
+		DO UPDATE SET webhook_url = $2, channel = $3, assigned_template = $4, reassigned_template = $5, is_enabled = $6`,
+		settings.TeamName, settings.WebhookURL, settings.Channel,
+		settings.AssignedTemplate, settings.ReassignedTemplate, settings.IsEnabled, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to upsert team chat settings: %w", err)
+	}
+
+	after := chatSettingsAudit(settings)
+	after["webhook_url_changed"] = before == nil || previous.WebhookURL != settings.WebhookURL
+	err = insertAuditEntry(ctx, tx, domain.AuditActionTeamSetChat, domain.AuditEntityTeam, settings.TeamName, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// chatSettingsAudit leaves the webhook URL out: incoming webhook URLs carry
+// the chat's access token.
+func chatSettingsAudit(settings *domain.TeamChatSettings) map[string]interface{} {
+	return map[string]interface{}{
+		"channel":             settings.Channel,
+		"assigned_template":   settings.AssignedTemplate,
+		"reassigned_template": settings.ReassignedTemplate,
+		"is_enabled":          settings.IsEnabled,
+	}
+}
+
+func (r *ChatSettingsRepository) GetTeamChatSettings(ctx context.Context, teamName string) (*domain.TeamChatSettings, error) {
+	var settings domain.TeamChatSettings
+	err := r.db.QueryRowContext(ctx, `
+		SELECT team_name, webhook_url, channel, assigned_template, reassigned_template, is_enabled
+		FROM team_chat_settings
+		WHERE organization_id = $2 AND team_name = $1`,
+		teamName, orgID(ctx)).Scan(&settings.TeamName, &settings.WebhookURL, &settings.Channel,
+		&settings.AssignedTemplate, &settings.ReassignedTemplate, &settings.IsEnabled)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "chat notifications are not configured for team"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team chat settings: %w", err)
+	}
+	return &settings, nil
+}
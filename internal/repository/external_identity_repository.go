@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type ExternalIdentityRepository struct {
+	db *sql.DB
+}
+
+func NewExternalIdentityRepository(db *sql.DB) *ExternalIdentityRepository {
+	return &ExternalIdentityRepository{db: db}
+}
+
+func (r *ExternalIdentityRepository) UpsertIdentity(ctx context.Context, identity *domain.ExternalIdentity) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userExists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE organization_id = $2 AND user_id = $1)",
+		identity.UserID, orgID(ctx)).Scan(&userExists)
+	if err != nil {
+		return fmt.Errorf("failed to check user existence: %w", err)
+	}
+	if !userExists {
+		return &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
+	}
+
+	var before interface{}
+	var previousUserID string
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id 
+		FROM external_identities 
+		WHERE organization_id = $3 AND provider = $1 AND external_login = $2
+		FOR UPDATE`,
+		identity.Provider, identity.ExternalLogin, orgID(ctx)).Scan(&previousUserID)
+	switch {
+	case err == nil:
+		before = &domain.ExternalIdentity{Provider: identity.Provider, ExternalLogin: identity.ExternalLogin, UserID: previousUserID}
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("failed to get external identity: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO external_identities (provider, external_login, user_id, organization_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, provider, external_login)
+		DO UPDATE SET user_id = $3`,
+		identity.Provider, identity.ExternalLogin, identity.UserID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to upsert external identity: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionUserMapIdentity, domain.AuditEntityUser, identity.UserID, before, identity); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ExternalIdentityRepository) GetUserID(ctx context.Context, provider, externalLogin string) (string, error) {
+	var userID string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT user_id 
+		FROM external_identities 
+		WHERE organization_id = $3 AND provider = $1 AND external_login = $2`,
+		provider, externalLogin, orgID(ctx)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", &domain.Error{Code: "UNMAPPED_USER", Message: fmt.Sprintf("%s login %s is not mapped to a user", provider, externalLogin)}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get external identity: %w", err)
+	}
+	return userID, nil
+}
+
+// GetExternalLogins maps user IDs to their login at provider. Users without a
+// mapping are left out.
+func (r *ExternalIdentityRepository) GetExternalLogins(ctx context.Context, provider string, userIDs []string) (map[string]string, error) {
+	logins := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return logins, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (user_id) user_id, external_login
+		FROM external_identities
+		WHERE organization_id = $3 AND provider = $1 AND user_id = ANY($2)
+		ORDER BY user_id, updated_at DESC`,
+		provider, pq.Array(userIDs), orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query external logins: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, login string
+		if err := rows.Scan(&userID, &login); err != nil {
+			return nil, fmt.Errorf("failed to scan external login: %w", err)
+		}
+		logins[userID] = login
+	}
+
+	return logins, rows.Err()
+}
+
+func (r *ExternalIdentityRepository) ListIdentities(ctx context.Context, provider string) ([]domain.ExternalIdentity, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT provider, external_login, user_id 
+		FROM external_identities 
+		WHERE organization_id = $2 AND ($1 = '' OR provider = $1)
+		ORDER BY provider, external_login`,
+		provider, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query external identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := make([]domain.ExternalIdentity, 0)
+	for rows.Next() {
+		var identity domain.ExternalIdentity
+		if err := rows.Scan(&identity.Provider, &identity.ExternalLogin, &identity.UserID); err != nil {
+			return nil, fmt.Errorf("failed to scan external identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+func (r *ExternalIdentityRepository) DeleteIdentity(ctx context.Context, provider, externalLogin string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	identity := domain.ExternalIdentity{Provider: provider, ExternalLogin: externalLogin}
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM external_identities 
+		WHERE organization_id = $3 AND provider = $1 AND external_login = $2
+		RETURNING user_id`,
+		provider, externalLogin, orgID(ctx)).Scan(&identity.UserID)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "mapping not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete external identity: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionUserUnmapIdentity, domain.AuditEntityUser, identity.UserID, &identity, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// orgID is the organization the queries of a request or worker run are
+// scoped to. Team names, user ids and PR ids are only unique within it.
+func orgID(ctx context.Context) string {
+	return auth.OrganizationFromContext(ctx)
+}
+
+func (r *OrganizationRepository) CreateOrganization(ctx context.Context, org *domain.Organization) error {
+	exists, err := r.OrganizationExists(ctx, org.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &domain.Error{Code: "ORGANIZATION_EXISTS", Message: "organization already exists"}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO organizations (organization_id, name, github_webhook_secret, gitlab_webhook_token)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+		RETURNING created_at`,
+		org.OrganizationID, org.Name, org.GitHubWebhookSecret, org.GitLabWebhookToken).Scan(&org.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert organization: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionOrgCreate, domain.AuditEntityOrg, org.OrganizationID, nil, org); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetOrganization returns the organization together with its webhook secrets.
+func (r *OrganizationRepository) GetOrganization(ctx context.Context, organizationID string) (*domain.Organization, error) {
+	var org domain.Organization
+	err := r.db.QueryRowContext(ctx, `
+		SELECT organization_id, name, COALESCE(github_webhook_secret, ''), COALESCE(gitlab_webhook_token, ''), created_at
+		FROM organizations
+		WHERE organization_id = $1`,
+		organizationID).Scan(&org.OrganizationID, &org.Name, &org.GitHubWebhookSecret, &org.GitLabWebhookToken, &org.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "organization not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &org, nil
+}
+
+// SetWebhookSecrets replaces the secrets of the organization's inbound
+// webhooks; an empty value turns the webhook off. The audit log only records
+// whether a secret is set.
+func (r *OrganizationRepository) SetWebhookSecrets(ctx context.Context, organizationID, githubSecret, gitlabToken string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hadGitHub, hadGitLab bool
+	err = tx.QueryRowContext(ctx, `
+		UPDATE organizations o 
+		SET github_webhook_secret = NULLIF($2, ''), gitlab_webhook_token = NULLIF($3, '') 
+		FROM organizations old 
+		WHERE old.organization_id = o.organization_id AND o.organization_id = $1
+		RETURNING old.github_webhook_secret IS NOT NULL, old.gitlab_webhook_token IS NOT NULL`,
+		organizationID, githubSecret, gitlabToken).Scan(&hadGitHub, &hadGitLab)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "organization not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set webhook secrets: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionOrgSetSecrets, domain.AuditEntityOrg, organizationID,
+		map[string]bool{"github_webhook_secret_set": hadGitHub, "gitlab_webhook_token_set": hadGitLab},
+		map[string]bool{"github_webhook_secret_set": githubSecret != "", "gitlab_webhook_token_set": gitlabToken != ""})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *OrganizationRepository) ListOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT organization_id, name, created_at
+		FROM organizations
+		ORDER BY organization_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := make([]domain.Organization, 0)
+	for rows.Next() {
+		var org domain.Organization
+		if err := rows.Scan(&org.OrganizationID, &org.Name, &org.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+func (r *OrganizationRepository) OrganizationExists(ctx context.Context, organizationID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM organizations WHERE organization_id = $1)",
+		organizationID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check organization existence: %w", err)
+	}
+	return exists, nil
+}
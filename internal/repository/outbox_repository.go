@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// insertOutboxEvent stores an event in the caller's transaction, so the event
+// exists if and only if the change it describes was committed. teamName and
+// userIDs say whom the event concerns and are used to filter the event stream.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType, teamName string, userIDs []string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO outbox_events (event_type, payload, team_name, user_ids, organization_id)
+		VALUES ($1, $2::jsonb, NULLIF($3, ''), $4, $5)`,
+		eventType, string(payload), teamName, pq.Array(nonNilStrings(userIDs)), orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+	return nil
+}
+
+// insertPREvent stores an event about a pull request; it is scoped to the
+// author's team and concerns the author plus the given reviewers.
+func insertPREvent(ctx context.Context, tx *sql.Tx, eventType, prID string, reviewerIDs []string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO outbox_events (event_type, payload, team_name, user_ids, organization_id)
+		SELECT $1, $2::jsonb, u.team_name, array_append($4::text[], p.author_id), p.organization_id
+		FROM pull_requests p
+		JOIN users u ON u.organization_id = p.organization_id AND u.user_id = p.author_id
+		WHERE p.organization_id = $5 AND p.pull_request_id = $3`,
+		eventType, string(payload), prID, pq.Array(nonNilStrings(reviewerIDs)), orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+	return nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// ClaimPending locks unpublished events and pushes their next attempt out by
+// lease, so concurrent relays skip them and a crashed relay's batch is retried.
+// Events of all organizations are claimed together.
+func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]domain.Event, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox_events
+		SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, organization_id, payload, attempts, published_sinks, created_at`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.Event
+	for rows.Next() {
+		var event domain.Event
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Type, &event.OrganizationID, &payload, &event.Attempts,
+			(*pq.StringArray)(&event.PublishedSinks), &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		event.Data = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// UPDATE ... RETURNING does not keep the subquery order.
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, eventID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox_events
+		SET published_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = NULL
+		WHERE id = $1`,
+		eventID)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event as published: %w", err)
+	}
+	return nil
+}
+
+// MarkSinkPublished records that a sink accepted the event, so that retries
+// skip it.
+func (r *OutboxRepository) MarkSinkPublished(ctx context.Context, eventID int64, sink string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox_events
+		SET published_sinks = array_append(published_sinks, $2)
+		WHERE id = $1 AND NOT ($2 = ANY(published_sinks))`,
+		eventID, sink)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox sink delivery: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt. Without nextAttemptAt the event is
+// dead-lettered and no longer retried.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, eventID int64, errMsg string, nextAttemptAt *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = COALESCE($3, next_attempt_at),
+			failed_at = CASE WHEN $3::timestamptz IS NULL THEN CURRENT_TIMESTAMP END
+		WHERE id = $1`,
+		eventID, errMsg, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox publish failure: %w", err)
+	}
+	return nil
+}
+
+// DeleteFinishedBefore deletes up to limit events that were published or
+// dead-lettered before cutoff and returns how many were deleted.
+func (r *OutboxRepository) DeleteFinishedBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM outbox_events
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE (published_at IS NOT NULL OR failed_at IS NOT NULL)
+				AND COALESCE(published_at, failed_at) < $1
+			LIMIT $2
+		)`,
+		cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old outbox events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+type StreamEvent struct {
+	domain.Event
+	Cursor domain.EventCursor
+}
+
+// CurrentCursor returns a cursor from which a new reader sees every event
+// committed from now on. It may also yield a few events committed just before.
+func (r *OutboxRepository) CurrentCursor(ctx context.Context) (domain.EventCursor, error) {
+	var xmin string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT pg_snapshot_xmin(pg_current_snapshot())::text").Scan(&xmin)
+	if err != nil {
+		return domain.EventCursor{}, fmt.Errorf("failed to get current snapshot: %w", err)
+	}
+
+	txID, err := strconv.ParseUint(xmin, 10, 64)
+	if err != nil {
+		return domain.EventCursor{}, fmt.Errorf("failed to parse snapshot xmin %q: %w", xmin, err)
+	}
+	return domain.EventCursor{TxID: txID}, nil
+}
+
+// GetEventsAfter returns events of the organization written after cursor,
+// optionally filtered by team or user. Only events of transactions older than every transaction still
+// running are returned, so a reader that advances its cursor past them cannot
+// miss a commit that is still in flight.
+func (r *OutboxRepository) GetEventsAfter(ctx context.Context, cursor domain.EventCursor, teamName, userID string, limit int) ([]StreamEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tx_id::text, id, event_type, organization_id, payload, created_at
+		FROM outbox_events
+		WHERE (tx_id, id) > ($1::text::xid8, $2)
+			AND tx_id < pg_snapshot_xmin(pg_current_snapshot())
+			AND organization_id = $6
+			AND ($3 = '' OR team_name = $3)
+			AND ($4 = '' OR $4 = ANY(user_ids))
+		ORDER BY tx_id, id
+		LIMIT $5`,
+		strconv.FormatUint(cursor.TxID, 10), cursor.ID, teamName, userID, limit, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stream events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []StreamEvent
+	for rows.Next() {
+		var event StreamEvent
+		var txID string
+		var payload []byte
+		if err := rows.Scan(&txID, &event.ID, &event.Type, &event.OrganizationID, &payload, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stream event: %w", err)
+		}
+		if event.Cursor.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse event transaction id %q: %w", txID, err)
+		}
+		event.Cursor.ID = event.ID
+		event.Data = json.RawMessage(payload)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -7,24 +7,31 @@ import (
 	"time"
 
 	"github.com/pavel/avitotech_previewer/internal/domain"
+
+	"github.com/lib/pq"
 )
 
 type PullRequestRepository struct {
 	db *sql.DB
 }
 
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 func NewPullRequestRepository(db *sql.DB) *PullRequestRepository {
 	return &PullRequestRepository{db: db}
 }
 
 func (r *PullRequestRepository) GetUserReviewPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, prr.review_state, prr.state_updated_at
 		FROM pull_requests pr
-		JOIN pull_request_reviewers prr ON pr.pull_request_id = prr.pull_request_id
-		WHERE prr.reviewer_id = $1
+		JOIN pull_request_reviewers prr
+			ON prr.organization_id = pr.organization_id AND prr.pull_request_id = pr.pull_request_id
+		WHERE pr.organization_id = $2 AND prr.reviewer_id = $1
 		ORDER BY pr.created_at DESC`,
-		userID)
+		userID, orgID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query user review PRs: %w", err)
 	}
@@ -33,7 +40,7 @@ func (r *PullRequestRepository) GetUserReviewPRs(ctx context.Context, userID str
 	var prs []domain.PullRequestShort
 	for rows.Next() {
 		var pr domain.PullRequestShort
-		if err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status); err != nil {
+		if err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &pr.ReviewState, &pr.StateUpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan PR: %w", err)
 		}
 		prs = append(prs, pr)
@@ -49,10 +56,11 @@ func (r *PullRequestRepository) CreatePR(ctx context.Context, pr *domain.PullReq
 	}
 	defer tx.Rollback()
 
+	org := orgID(ctx)
 	var exists bool
 	err = tx.QueryRowContext(ctx,
-		"SELECT EXISTS(SELECT 1 FROM pull_requests WHERE pull_request_id = $1)",
-		pr.PullRequestID).Scan(&exists)
+		"SELECT EXISTS(SELECT 1 FROM pull_requests WHERE organization_id = $2 AND pull_request_id = $1)",
+		pr.PullRequestID, org).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check PR existence: %w", err)
 	}
@@ -62,8 +70,8 @@ func (r *PullRequestRepository) CreatePR(ctx context.Context, pr *domain.PullReq
 
 	var authorExists bool
 	err = tx.QueryRowContext(ctx,
-		"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND is_active = true)",
-		pr.AuthorID).Scan(&authorExists)
+		"SELECT EXISTS(SELECT 1 FROM users WHERE organization_id = $2 AND user_id = $1 AND is_active = true)",
+		pr.AuthorID, org).Scan(&authorExists)
 	if err != nil {
 		return fmt.Errorf("failed to check author existence: %w", err)
 	}
@@ -71,23 +79,53 @@ func (r *PullRequestRepository) CreatePR(ctx context.Context, pr *domain.PullReq
 		return &domain.Error{Code: "NOT_FOUND", Message: "author not found or inactive"}
 	}
 
+	var sourceProvider, sourceProject, externalID sql.NullString
+	if pr.Source != nil {
+		sourceProvider = sql.NullString{String: pr.Source.Provider, Valid: true}
+		sourceProject = sql.NullString{String: pr.Source.Project, Valid: true}
+		externalID = sql.NullString{String: pr.Source.ExternalID, Valid: true}
+
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS(
+				SELECT 1 FROM pull_requests 
+				WHERE organization_id = $4 AND source_provider = $1 AND source_project = $2 AND external_id = $3
+			)`,
+			sourceProvider, sourceProject, externalID, org).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check PR source existence: %w", err)
+		}
+		if exists {
+			return &domain.Error{Code: "PR_EXISTS", Message: "PR from this source already exists"}
+		}
+	}
+
 	now := time.Now()
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, created_at)
-		VALUES ($1, $2, $3, $4, $5)`,
-		pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, now)
+		INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, pending_reviewers, 
+			source_provider, source_project, external_id, created_at, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, pr.PendingReviewers,
+		sourceProvider, sourceProject, externalID, now, org)
 	if err != nil {
 		return fmt.Errorf("failed to insert PR: %w", err)
 	}
 
-	for _, reviewerID := range reviewerIDs {
-		_, err = tx.ExecContext(ctx, `
-			INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id)
-			VALUES ($1, $2)`,
-			pr.PullRequestID, reviewerID)
-		if err != nil {
-			return fmt.Errorf("failed to assign reviewer %s: %w", reviewerID, err)
-		}
+	assigned, err := assignReviewers(ctx, tx, pr.PullRequestID, reviewerIDs, domain.AssignmentReasonCreate)
+	if err != nil {
+		return err
+	}
+
+	created := *pr
+	created.CreatedAt = &now
+	created.AssignedReviewers = assigned
+	if err := insertPREvent(ctx, tx, domain.EventPRCreated, pr.PullRequestID, assigned, &created); err != nil {
+		return err
+	}
+	if err := insertAssignedEvent(ctx, tx, pr.PullRequestID, assigned, domain.AssignmentReasonCreate); err != nil {
+		return err
+	}
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionPRCreate, domain.AuditEntityPullRequest, pr.PullRequestID, nil, &created); err != nil {
+		return err
 	}
 
 	return tx.Commit()
@@ -95,11 +133,14 @@ func (r *PullRequestRepository) CreatePR(ctx context.Context, pr *domain.PullReq
 
 func (r *PullRequestRepository) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
 	var pr domain.PullRequest
+	var sourceProvider, sourceProject, externalID sql.NullString
 	err := r.db.QueryRowContext(ctx, `
-		SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at
+		SELECT pull_request_id, pull_request_name, author_id, status, pending_reviewers, created_at, merged_at, closed_at,
+			last_activity_at, stale_at, source_provider, source_project, external_id
 		FROM pull_requests 
-		WHERE pull_request_id = $1`,
-		prID).Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &pr.MergedAt)
+		WHERE organization_id = $2 AND pull_request_id = $1`,
+		prID, orgID(ctx)).Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &pr.PendingReviewers,
+		&pr.CreatedAt, &pr.MergedAt, &pr.ClosedAt, &pr.LastActivityAt, &pr.StaleAt, &sourceProvider, &sourceProject, &externalID)
 	if err == sql.ErrNoRows {
 		return nil, &domain.Error{Code: "NOT_FOUND", Message: "PR not found"}
 	}
@@ -107,25 +148,43 @@ func (r *PullRequestRepository) GetPR(ctx context.Context, prID string) (*domain
 		return nil, fmt.Errorf("failed to get PR: %w", err)
 	}
 
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT reviewer_id 
+	if sourceProvider.Valid {
+		pr.Source = &domain.PullRequestSource{
+			Provider:   sourceProvider.String,
+			Project:    sourceProject.String,
+			ExternalID: externalID.String,
+		}
+	}
+
+	if err := loadReviews(ctx, r.db, &pr); err != nil {
+		return nil, err
+	}
+
+	return &pr, nil
+}
+
+func loadReviews(ctx context.Context, q querier, pr *domain.PullRequest) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT reviewer_id, review_state, assigned_at, state_updated_at 
 		FROM pull_request_reviewers 
-		WHERE pull_request_id = $1`,
-		prID)
+		WHERE organization_id = $2 AND pull_request_id = $1
+		ORDER BY assigned_at, reviewer_id`,
+		pr.PullRequestID, orgID(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get reviewers: %w", err)
+		return fmt.Errorf("failed to get reviewers: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var reviewerID string
-		if err := rows.Scan(&reviewerID); err != nil {
-			return nil, fmt.Errorf("failed to scan reviewer: %w", err)
+		var review domain.Review
+		if err := rows.Scan(&review.ReviewerID, &review.State, &review.AssignedAt, &review.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan reviewer: %w", err)
 		}
-		pr.AssignedReviewers = append(pr.AssignedReviewers, reviewerID)
+		pr.AssignedReviewers = append(pr.AssignedReviewers, review.ReviewerID)
+		pr.Reviews = append(pr.Reviews, review)
 	}
 
-	return &pr, nil
+	return rows.Err()
 }
 
 func (r *PullRequestRepository) MergePR(ctx context.Context, prID string) (*domain.PullRequest, error) {
@@ -135,14 +194,25 @@ func (r *PullRequestRepository) MergePR(ctx context.Context, prID string) (*doma
 	}
 	defer tx.Rollback()
 
+	var previousStatus string
+	err = tx.QueryRowContext(ctx,
+		"SELECT status FROM pull_requests WHERE organization_id = $2 AND pull_request_id = $1 FOR UPDATE",
+		prID, orgID(ctx)).Scan(&previousStatus)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "PR not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock PR: %w", err)
+	}
+
 	var pr domain.PullRequest
 	now := time.Now()
 	err = tx.QueryRowContext(ctx, `
 		UPDATE pull_requests 
-		SET status = 'MERGED', merged_at = $1, updated_at = CURRENT_TIMESTAMP
-		WHERE pull_request_id = $2
+		SET status = 'MERGED', merged_at = $1, pending_reviewers = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $3 AND pull_request_id = $2
 		RETURNING pull_request_id, pull_request_name, author_id, status, created_at, merged_at`,
-		&now, prID).Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &pr.MergedAt)
+		&now, prID, orgID(ctx)).Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &pr.MergedAt)
 	if err == sql.ErrNoRows {
 		return nil, &domain.Error{Code: "NOT_FOUND", Message: "PR not found"}
 	}
@@ -150,66 +220,499 @@ func (r *PullRequestRepository) MergePR(ctx context.Context, prID string) (*doma
 		return nil, fmt.Errorf("failed to merge PR: %w", err)
 	}
 
+	if err := loadReviews(ctx, tx, &pr); err != nil {
+		return nil, err
+	}
+
+	if previousStatus != domain.PRStatusMerged {
+		if err := insertPREvent(ctx, tx, domain.EventPRMerged, prID, pr.AssignedReviewers, &pr); err != nil {
+			return nil, err
+		}
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionPRMerge, domain.AuditEntityPullRequest, prID,
+		map[string]interface{}{"status": previousStatus},
+		map[string]interface{}{"status": pr.Status, "merged_at": pr.MergedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &pr, nil
+}
+
+func (r *PullRequestRepository) ClosePR(ctx context.Context, prID, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previousStatus string
+	err = tx.QueryRowContext(ctx,
+		"SELECT status FROM pull_requests WHERE organization_id = $2 AND pull_request_id = $1 FOR UPDATE",
+		prID, orgID(ctx)).Scan(&previousStatus)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "PR not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock PR: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE pull_requests 
+		SET status = 'CLOSED', closed_at = CURRENT_TIMESTAMP, pending_reviewers = 0, stale_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $2 AND pull_request_id = $1 AND status IN ('DRAFT', 'OPEN')`,
+		prID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to close PR: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &domain.Error{Code: "INVALID_TRANSITION", Message: "PR cannot be closed in its current status"}
+	}
+
+	reviewers, err := getReviewerIDs(ctx, tx, prID)
+	if err != nil {
+		return err
+	}
+	if err := insertPREvent(ctx, tx, domain.EventPRClosed, prID, reviewers, domain.PRClosedData{PullRequestID: prID, Reason: reason}); err != nil {
+		return err
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionPRClose, domain.AuditEntityPullRequest, prID,
+		map[string]interface{}{"status": previousStatus},
+		map[string]interface{}{"status": domain.PRStatusClosed, "reason": reason})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// OpenPR moves a DRAFT or CLOSED PR to OPEN and assigns the given reviewers in
+// the same transaction.
+func (r *PullRequestRepository) OpenPR(ctx context.Context, prID, fromStatus string, reviewerIDs []string, pendingReviewers int, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE pull_requests 
+		SET status = 'OPEN', closed_at = NULL, pending_reviewers = $1, last_activity_at = CURRENT_TIMESTAMP, stale_at = NULL, 
+			updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $4 AND pull_request_id = $2 AND status = $3`,
+		pendingReviewers, prID, fromStatus, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to open PR: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &domain.Error{Code: "INVALID_TRANSITION", Message: "PR status changed concurrently"}
+	}
+
+	assigned, err := assignReviewers(ctx, tx, prID, reviewerIDs, reason)
+	if err != nil {
+		return err
+	}
+
+	if err := insertAssignedEvent(ctx, tx, prID, assigned, reason); err != nil {
+		return err
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionPROpen, domain.AuditEntityPullRequest, prID,
+		map[string]interface{}{"status": fromStatus},
+		map[string]interface{}{"status": domain.PRStatusOpen, "assigned_reviewers": nonNilStrings(assigned), "reason": reason})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PullRequestRepository) UpdatePRReviewers(ctx context.Context, prID string, reviewerIDs []string, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if reviewerIDs == nil {
+		reviewerIDs = []string{}
+	}
+
+	previousReviewers, err := getReviewerIDs(ctx, tx, prID)
+	if err != nil {
+		return err
+	}
+
 	rows, err := tx.QueryContext(ctx, `
-		SELECT reviewer_id 
-		FROM pull_request_reviewers 
-		WHERE pull_request_id = $1`,
-		prID)
+		DELETE FROM pull_request_reviewers 
+		WHERE organization_id = $3 AND pull_request_id = $1 AND NOT (reviewer_id = ANY($2))
+		RETURNING reviewer_id`,
+		prID, pq.Array(reviewerIDs), orgID(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get reviewers: %w", err)
+		return fmt.Errorf("failed to delete old reviewers: %w", err)
 	}
-	defer rows.Close()
 
+	var removed []string
 	for rows.Next() {
 		var reviewerID string
 		if err := rows.Scan(&reviewerID); err != nil {
-			return nil, fmt.Errorf("failed to scan reviewer: %w", err)
+			rows.Close()
+			return fmt.Errorf("failed to scan removed reviewer: %w", err)
 		}
-		pr.AssignedReviewers = append(pr.AssignedReviewers, reviewerID)
+		removed = append(removed, reviewerID)
 	}
+	rows.Close()
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	for _, reviewerID := range removed {
+		if err := insertAssignmentHistory(ctx, tx, prID, reviewerID, domain.AssignmentActionUnassigned, reason); err != nil {
+			return err
+		}
 	}
 
-	return &pr, nil
+	assigned, err := assignReviewers(ctx, tx, prID, reviewerIDs, reason)
+	if err != nil {
+		return err
+	}
+
+	// Automatic reassignments do not count as activity, otherwise repeated SLA
+	// escalations would keep an abandoned PR from ever going stale.
+	if reason == domain.AssignmentReasonManual {
+		if err := touchPR(ctx, tx, prID); err != nil {
+			return err
+		}
+	}
+
+	// Each removed reviewer is reported as replaced by the next newly assigned
+	// one; assignments beyond the removed count are plain assignments.
+	for i := 0; i < len(removed) && i < len(assigned); i++ {
+		if err := insertReassignedEvent(ctx, tx, prID, removed[i], assigned[i], reason); err != nil {
+			return err
+		}
+	}
+	if len(assigned) > len(removed) {
+		if err := insertAssignedEvent(ctx, tx, prID, assigned[len(removed):], reason); err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 || len(assigned) > 0 {
+		err = insertAuditEntry(ctx, tx, domain.AuditActionPRReassign, domain.AuditEntityPullRequest, prID,
+			map[string]interface{}{"reviewers": nonNilStrings(previousReviewers)},
+			map[string]interface{}{"reviewers": reviewerIDs, "reason": reason})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PullRequestRepository) SubmitReview(ctx context.Context, prID, reviewerID, state string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previousState string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE pull_request_reviewers r
+		SET review_state = $1, state_updated_at = CURRENT_TIMESTAMP
+		FROM pull_request_reviewers old
+		WHERE r.organization_id = $4 AND r.pull_request_id = $2 AND r.reviewer_id = $3 AND old.id = r.id
+		RETURNING old.review_state`,
+		state, prID, reviewerID, orgID(ctx)).Scan(&previousState)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_ASSIGNED", Message: "reviewer is not assigned to this PR"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to submit review: %w", err)
+	}
+
+	if err := touchPR(ctx, tx, prID); err != nil {
+		return err
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionPRReview, domain.AuditEntityPullRequest, prID,
+		map[string]string{"reviewer_id": reviewerID, "review_state": previousState},
+		map[string]string{"reviewer_id": reviewerID, "review_state": state})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TouchPR records activity on an open PR, e.g. new commits pushed to it,
+// and takes it out of the stale state.
+func (r *PullRequestRepository) TouchPR(ctx context.Context, prID string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pull_requests 
+		SET last_activity_at = CURRENT_TIMESTAMP, stale_at = NULL 
+		WHERE organization_id = $2 AND pull_request_id = $1 AND status = 'OPEN'`,
+		prID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to record PR activity: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &domain.Error{Code: "NOT_FOUND", Message: "open PR not found"}
+	}
+
+	return nil
 }
 
-func (r *PullRequestRepository) UpdatePRReviewers(ctx context.Context, prID string, reviewerIDs []string) error {
+func touchPR(ctx context.Context, tx *sql.Tx, prID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE pull_requests 
+		SET last_activity_at = CURRENT_TIMESTAMP, stale_at = NULL 
+		WHERE organization_id = $2 AND pull_request_id = $1`,
+		prID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to record PR activity: %w", err)
+	}
+	return nil
+}
+
+func (r *PullRequestRepository) AddPRReviewers(ctx context.Context, prID string, reviewerIDs []string, reason string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	previousReviewers, err := getReviewerIDs(ctx, tx, prID)
+	if err != nil {
+		return err
+	}
+
+	assigned, err := assignReviewers(ctx, tx, prID, reviewerIDs, reason)
+	if err != nil {
+		return err
+	}
+
 	_, err = tx.ExecContext(ctx, `
-		DELETE FROM pull_request_reviewers 
-		WHERE pull_request_id = $1`,
-		prID)
+		UPDATE pull_requests 
+		SET pending_reviewers = GREATEST(pending_reviewers - $1, 0), queue_next_attempt_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $3 AND pull_request_id = $2`,
+		len(assigned), prID, orgID(ctx))
 	if err != nil {
-		return fmt.Errorf("failed to delete old reviewers: %w", err)
+		return fmt.Errorf("failed to update pending reviewers: %w", err)
 	}
 
-	for _, reviewerID := range reviewerIDs {
-		_, err = tx.ExecContext(ctx, `
-			INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id)
-			VALUES ($1, $2)`,
-			prID, reviewerID)
+	if err := insertAssignedEvent(ctx, tx, prID, assigned, reason); err != nil {
+		return err
+	}
+
+	if len(assigned) > 0 {
+		err = insertAuditEntry(ctx, tx, domain.AuditActionPRAddReviewers, domain.AuditEntityPullRequest, prID,
+			map[string]interface{}{"reviewers": nonNilStrings(previousReviewers)},
+			map[string]interface{}{"reviewers": append(nonNilStrings(previousReviewers), assigned...), "reason": reason})
 		if err != nil {
-			return fmt.Errorf("failed to assign reviewer %s: %w", reviewerID, err)
+			return err
 		}
 	}
 
 	return tx.Commit()
 }
 
+func (r *PullRequestRepository) GetAssignmentHistory(ctx context.Context, prID string) ([]domain.ReviewerAssignmentEvent, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM pull_requests WHERE organization_id = $2 AND pull_request_id = $1)",
+		prID, orgID(ctx)).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check PR existence: %w", err)
+	}
+	if !exists {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "PR not found"}
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, reviewer_id, action, reason, created_at
+		FROM pull_request_reviewer_history
+		WHERE organization_id = $2 AND pull_request_id = $1
+		ORDER BY id`,
+		prID, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assignment history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make([]domain.ReviewerAssignmentEvent, 0)
+	for rows.Next() {
+		var event domain.ReviewerAssignmentEvent
+		if err := rows.Scan(&event.ID, &event.ReviewerID, &event.Action, &event.Reason, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan assignment history: %w", err)
+		}
+		history = append(history, event)
+	}
+
+	return history, nil
+}
+
+// assignReviewers inserts reviewers that are not assigned yet and records each
+// new assignment in the history. It returns the reviewers actually added.
+func assignReviewers(ctx context.Context, tx *sql.Tx, prID string, reviewerIDs []string, reason string) ([]string, error) {
+	var assigned []string
+	for _, reviewerID := range reviewerIDs {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id, organization_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, pull_request_id, reviewer_id) DO NOTHING`,
+			prID, reviewerID, orgID(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign reviewer %s: %w", reviewerID, err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			continue
+		}
+
+		if err := insertAssignmentHistory(ctx, tx, prID, reviewerID, domain.AssignmentActionAssigned, reason); err != nil {
+			return nil, err
+		}
+		assigned = append(assigned, reviewerID)
+	}
+	return assigned, nil
+}
+
+func insertAssignmentHistory(ctx context.Context, tx *sql.Tx, prID, reviewerID, action, reason string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO pull_request_reviewer_history (pull_request_id, reviewer_id, action, reason, organization_id)
+		VALUES ($1, $2, $3, $4, $5)`,
+		prID, reviewerID, action, reason, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to record assignment history: %w", err)
+	}
+	return nil
+}
+
+func insertAssignedEvent(ctx context.Context, tx *sql.Tx, prID string, reviewerIDs []string, reason string) error {
+	if len(reviewerIDs) == 0 {
+		return nil
+	}
+	return insertPREvent(ctx, tx, domain.EventReviewersAssigned, prID, reviewerIDs, domain.ReviewersAssignedData{
+		PullRequestID: prID,
+		Reviewers:     reviewerIDs,
+		Reason:        reason,
+	})
+}
+
+func insertReassignedEvent(ctx context.Context, tx *sql.Tx, prID, oldReviewerID, newReviewerID, reason string) error {
+	var oldTeam, newTeam string
+	err := tx.QueryRowContext(ctx, `
+		SELECT 
+			(SELECT team_name FROM users WHERE organization_id = $3 AND user_id = $1),
+			(SELECT team_name FROM users WHERE organization_id = $3 AND user_id = $2)`,
+		oldReviewerID, newReviewerID, orgID(ctx)).Scan(&oldTeam, &newTeam)
+	if err != nil {
+		return fmt.Errorf("failed to get reviewer teams: %w", err)
+	}
+
+	data := domain.ReviewerReassignedData{
+		PullRequestID: prID,
+		OldReviewerID: oldReviewerID,
+		NewReviewerID: newReviewerID,
+		Reason:        reason,
+	}
+	if newTeam != oldTeam {
+		data.BorrowedFrom = newTeam
+	}
+
+	return insertPREvent(ctx, tx, domain.EventReviewerReassigned, prID, []string{oldReviewerID, newReviewerID}, data)
+}
+
+func (r *PullRequestRepository) GetPRsAwaitingReviewers(ctx context.Context, limit int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT pull_request_id
+		FROM pull_requests
+		WHERE organization_id = $2 AND status = 'OPEN' AND pending_reviewers > 0
+			AND (queue_next_attempt_at IS NULL OR queue_next_attempt_at <= CURRENT_TIMESTAMP)
+		ORDER BY queue_next_attempt_at NULLS FIRST, created_at
+		LIMIT $1`,
+		limit, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query PRs awaiting reviewers: %w", err)
+	}
+	defer rows.Close()
+
+	var prIDs []string
+	for rows.Next() {
+		var prID string
+		if err := rows.Scan(&prID); err != nil {
+			return nil, fmt.Errorf("failed to scan PR ID: %w", err)
+		}
+		prIDs = append(prIDs, prID)
+	}
+
+	return prIDs, nil
+}
+
+// DeferQueuedPR skips a queued PR until nextAttemptAt.
+func (r *PullRequestRepository) DeferQueuedPR(ctx context.Context, prID string, nextAttemptAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE pull_requests 
+		SET queue_next_attempt_at = $2 
+		WHERE organization_id = $3 AND pull_request_id = $1`,
+		prID, nextAttemptAt, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to defer queued PR: %w", err)
+	}
+	return nil
+}
+
+// ResumeQueuedPRs makes deferred queued PRs of the reviewers' teams due
+// again, e.g. after a merge freed capacity there.
+func (r *PullRequestRepository) ResumeQueuedPRs(ctx context.Context, reviewerIDs []string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE pull_requests p
+		SET queue_next_attempt_at = NULL
+		FROM users author
+		WHERE p.organization_id = $2 AND p.status = 'OPEN' AND p.pending_reviewers > 0
+			AND p.queue_next_attempt_at IS NOT NULL
+			AND author.organization_id = p.organization_id AND author.user_id = p.author_id
+			AND author.team_name IN (
+				SELECT team_name FROM users WHERE organization_id = $2 AND user_id = ANY($1)
+			)`,
+		pq.Array(reviewerIDs), orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to resume queued PRs: %w", err)
+	}
+	return nil
+}
+
 func (r *PullRequestRepository) GetTeamActiveUsers(ctx context.Context, teamName string, excludeUserID string) ([]string, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT user_id 
 		FROM users 
-		WHERE team_name = $1 AND is_active = true AND user_id != $2
+		WHERE organization_id = $3 AND team_name = $1 AND is_active = true AND user_id != $2
 		ORDER BY user_id`,
-		teamName, excludeUserID)
+		teamName, excludeUserID, orgID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query team users: %w", err)
 	}
@@ -227,13 +730,55 @@ func (r *PullRequestRepository) GetTeamActiveUsers(ctx context.Context, teamName
 	return userIDs, nil
 }
 
+func (r *PullRequestRepository) GetReviewerCandidates(ctx context.Context, teamName string, excludeUserIDs []string) ([]domain.ReviewerCandidate, error) {
+	if excludeUserIDs == nil {
+		excludeUserIDs = []string{}
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.user_id, u.review_weight,
+			COUNT(pr.pull_request_id) FILTER (WHERE pr.status = 'OPEN') AS open_reviews,
+			COALESCE(u.max_open_reviews, t.default_max_open_reviews, 0) AS max_open_reviews,
+			MAX(prr.assigned_at) AS last_assigned_at
+		FROM users u
+		JOIN teams t ON t.organization_id = u.organization_id AND t.team_name = u.team_name
+		LEFT JOIN pull_request_reviewers prr
+			ON prr.organization_id = u.organization_id AND prr.reviewer_id = u.user_id
+		LEFT JOIN pull_requests pr
+			ON pr.organization_id = prr.organization_id AND pr.pull_request_id = prr.pull_request_id
+		WHERE u.organization_id = $3 AND u.team_name = $1 AND u.is_active = true AND NOT (u.user_id = ANY($2))
+			AND NOT EXISTS (
+				SELECT 1 FROM user_absences a
+				WHERE a.organization_id = u.organization_id AND a.user_id = u.user_id
+					AND a.starts_at <= CURRENT_TIMESTAMP AND a.ends_at > CURRENT_TIMESTAMP
+			)
+		GROUP BY u.user_id, u.review_weight, u.max_open_reviews, t.default_max_open_reviews
+		ORDER BY u.user_id`,
+		teamName, pq.Array(excludeUserIDs), orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reviewer candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []domain.ReviewerCandidate
+	for rows.Next() {
+		var candidate domain.ReviewerCandidate
+		if err := rows.Scan(&candidate.UserID, &candidate.Weight, &candidate.OpenReviews, &candidate.MaxOpenReviews, &candidate.LastAssignedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reviewer candidate: %w", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, nil
+}
+
 func (r *PullRequestRepository) GetUserTeam(ctx context.Context, userID string) (string, error) {
 	var teamName string
 	err := r.db.QueryRowContext(ctx, `
 		SELECT team_name 
 		FROM users 
-		WHERE user_id = $1`,
-		userID).Scan(&teamName)
+		WHERE organization_id = $2 AND user_id = $1`,
+		userID, orgID(ctx)).Scan(&teamName)
 	if err == sql.ErrNoRows {
 		return "", &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
 	}
@@ -247,9 +792,10 @@ func (r *PullRequestRepository) GetOpenPRsWithReviewer(ctx context.Context, revi
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT DISTINCT pr.pull_request_id
 		FROM pull_requests pr
-		JOIN pull_request_reviewers prr ON pr.pull_request_id = prr.pull_request_id
-		WHERE prr.reviewer_id = $1 AND pr.status = 'OPEN'
-	`, reviewerID)
+		JOIN pull_request_reviewers prr
+			ON prr.organization_id = pr.organization_id AND prr.pull_request_id = pr.pull_request_id
+		WHERE pr.organization_id = $2 AND prr.reviewer_id = $1 AND pr.status = 'OPEN'
+	`, reviewerID, orgID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query PRs with reviewer: %w", err)
 	}
@@ -266,3 +812,182 @@ func (r *PullRequestRepository) GetOpenPRsWithReviewer(ctx context.Context, revi
 
 	return prIDs, nil
 }
+
+// GetOverdueReviews returns pending assignments on open PRs whose team has an
+// SLA and which are older than the nearest threshold in wall-clock time. The
+// caller decides whether the threshold has really passed in business hours.
+// Assignments deferred with DeferOverdueCheck are skipped until their next
+// check, and those never checked come first.
+func (r *PullRequestRepository) GetOverdueReviews(ctx context.Context, limit int) ([]domain.OverdueReview, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT prr.pull_request_id, prr.reviewer_id, prr.assigned_at, prr.sla_reminded_at, 
+			t.sla_reminder_hours, t.sla_escalation_hours
+		FROM pull_request_reviewers prr
+		JOIN pull_requests pr ON pr.organization_id = prr.organization_id AND pr.pull_request_id = prr.pull_request_id
+		JOIN users a ON a.organization_id = pr.organization_id AND a.user_id = pr.author_id
+		JOIN teams t ON t.organization_id = a.organization_id AND t.team_name = a.team_name
+		WHERE prr.organization_id = $2 AND pr.status = 'OPEN' AND prr.review_state = 'PENDING'
+			AND prr.assigned_at <= CURRENT_TIMESTAMP - make_interval(hours => LEAST(
+				CASE WHEN prr.sla_reminded_at IS NULL THEN NULLIF(t.sla_reminder_hours, 0) END,
+				NULLIF(t.sla_escalation_hours, 0)))
+			AND (prr.sla_next_check_at IS NULL OR prr.sla_next_check_at <= CURRENT_TIMESTAMP)
+		ORDER BY prr.sla_next_check_at NULLS FIRST, prr.assigned_at
+		LIMIT $1`,
+		limit, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overdue reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []domain.OverdueReview
+	for rows.Next() {
+		var review domain.OverdueReview
+		if err := rows.Scan(&review.PullRequestID, &review.ReviewerID, &review.AssignedAt, &review.RemindedAt,
+			&review.SLAReminderHours, &review.SLAEscalationHours); err != nil {
+			return nil, fmt.Errorf("failed to scan overdue review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// MarkReviewReminded records that the reviewer was reminded about the
+// assignment and emits the reminder event. A reminder is sent once per
+// assignment; repeated calls are no-ops.
+func (r *PullRequestRepository) MarkReviewReminded(ctx context.Context, data domain.ReviewOverdueData) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE pull_request_reviewers 
+		SET sla_reminded_at = CURRENT_TIMESTAMP 
+		WHERE organization_id = $3 AND pull_request_id = $1 AND reviewer_id = $2 AND sla_reminded_at IS NULL`,
+		data.PullRequestID, data.ReviewerID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to mark review reminded: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+
+	if err := insertPREvent(ctx, tx, domain.EventReviewOverdue, data.PullRequestID, []string{data.ReviewerID}, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeferOverdueCheck keeps an overdue assignment out of GetOverdueReviews until
+// nextCheckAt.
+func (r *PullRequestRepository) DeferOverdueCheck(ctx context.Context, prID, reviewerID string, nextCheckAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE pull_request_reviewers 
+		SET sla_next_check_at = $3 
+		WHERE organization_id = $4 AND pull_request_id = $1 AND reviewer_id = $2`,
+		prID, reviewerID, nextCheckAt, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to defer overdue review check: %w", err)
+	}
+	return nil
+}
+
+func getReviewerIDs(ctx context.Context, tx *sql.Tx, prID string) ([]string, error) {
+	var reviewerIDs pq.StringArray
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(array_agg(reviewer_id ORDER BY reviewer_id), '{}') 
+		FROM pull_request_reviewers 
+		WHERE organization_id = $2 AND pull_request_id = $1`,
+		prID, orgID(ctx)).Scan(&reviewerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviewers: %w", err)
+	}
+	return reviewerIDs, nil
+}
+
+// GetStalePRs returns open PRs the stale policy of their author's team would
+// act on now: those idle for stale_after_days are to be marked stale, and
+// those stale for stale_close_after_days are to be closed. An empty teamName
+// means all teams; a zero limit means no limit.
+func (r *PullRequestRepository) GetStalePRs(ctx context.Context, teamName string, limit int) ([]domain.StalePR, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.pull_request_id, p.pull_request_name, p.author_id, u.team_name, p.last_activity_at, p.stale_at, 
+			t.stale_close_after_days
+		FROM pull_requests p
+		JOIN users u ON u.organization_id = p.organization_id AND u.user_id = p.author_id
+		JOIN teams t ON t.organization_id = u.organization_id AND t.team_name = u.team_name
+		WHERE p.organization_id = $3 AND p.status = 'OPEN' AND t.stale_after_days > 0 AND ($1 = '' OR u.team_name = $1)
+			AND (
+				(p.stale_at IS NULL AND p.last_activity_at <= CURRENT_TIMESTAMP - make_interval(days => t.stale_after_days))
+				OR (p.stale_at IS NOT NULL AND t.stale_close_after_days > 0 
+					AND p.stale_at <= CURRENT_TIMESTAMP - make_interval(days => t.stale_close_after_days))
+			)
+		ORDER BY p.last_activity_at, p.pull_request_id
+		LIMIT NULLIF($2, 0)`,
+		teamName, limit, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stale PRs: %w", err)
+	}
+	defer rows.Close()
+
+	stale := make([]domain.StalePR, 0)
+	for rows.Next() {
+		var pr domain.StalePR
+		if err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.TeamName, &pr.LastActivityAt, &pr.StaleAt,
+			&pr.CloseAfterDays); err != nil {
+			return nil, fmt.Errorf("failed to scan stale PR: %w", err)
+		}
+		pr.Action = domain.StaleActionMark
+		if pr.StaleAt != nil {
+			pr.Action = domain.StaleActionClose
+		}
+		stale = append(stale, pr)
+	}
+
+	return stale, rows.Err()
+}
+
+// MarkPRStale flags the PR as stale and emits the notification event, unless
+// there was activity on it after data.LastActivityAt.
+func (r *PullRequestRepository) MarkPRStale(ctx context.Context, data domain.PRStaleData) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE pull_requests 
+		SET stale_at = CURRENT_TIMESTAMP 
+		WHERE organization_id = $3 AND pull_request_id = $1 AND status = 'OPEN' AND stale_at IS NULL AND last_activity_at = $2`,
+		data.PullRequestID, data.LastActivityAt, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to mark PR stale: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+
+	reviewers, err := getReviewerIDs(ctx, tx, data.PullRequestID)
+	if err != nil {
+		return err
+	}
+	if err := insertPREvent(ctx, tx, domain.EventPRStale, data.PullRequestID, reviewers, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
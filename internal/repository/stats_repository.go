@@ -16,32 +16,42 @@ func NewStatsRepository(db *sql.DB) *StatsRepository {
 
 func (r *StatsRepository) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
+	org := orgID(ctx)
 
 	// Общее количество PR по статусам
-	var openCount, mergedCount int
+	var draftCount, openCount, staleCount, mergedCount, closedCount int
 	err := r.db.QueryRowContext(ctx, `
 		SELECT 
+			COUNT(*) FILTER (WHERE status = 'DRAFT') as draft_count,
 			COUNT(*) FILTER (WHERE status = 'OPEN') as open_count,
-			COUNT(*) FILTER (WHERE status = 'MERGED') as merged_count
+			COUNT(*) FILTER (WHERE status = 'OPEN' AND stale_at IS NOT NULL) as stale_count,
+			COUNT(*) FILTER (WHERE status = 'MERGED') as merged_count,
+			COUNT(*) FILTER (WHERE status = 'CLOSED') as closed_count
 		FROM pull_requests
-	`).Scan(&openCount, &mergedCount)
+		WHERE organization_id = $1
+	`, org).Scan(&draftCount, &openCount, &staleCount, &mergedCount, &closedCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get PR counts: %w", err)
 	}
 
 	stats["pull_requests"] = map[string]int{
+		"draft":  draftCount,
 		"open":   openCount,
+		"stale":  staleCount,
 		"merged": mergedCount,
-		"total":  openCount + mergedCount,
+		"closed": closedCount,
+		"total":  draftCount + openCount + mergedCount + closedCount,
 	}
 
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT u.user_id, u.username, COUNT(prr.pull_request_id) as assignment_count
 		FROM users u
-		LEFT JOIN pull_request_reviewers prr ON u.user_id = prr.reviewer_id
+		LEFT JOIN pull_request_reviewers prr
+			ON prr.organization_id = u.organization_id AND prr.reviewer_id = u.user_id
+		WHERE u.organization_id = $1
 		GROUP BY u.user_id, u.username
 		ORDER BY assignment_count DESC
-	`)
+	`, org)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get assignment stats: %w", err)
 	}
@@ -79,9 +89,10 @@ func (r *StatsRepository) getTeamStats(ctx context.Context) (map[string]interfac
 			COUNT(*) FILTER (WHERE is_active = true) as active_users,
 			COUNT(*) FILTER (WHERE is_active = false) as inactive_users
 		FROM users
+		WHERE organization_id = $1
 		GROUP BY team_name
 		ORDER BY team_name
-	`)
+	`, orgID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get team stats: %w", err)
 	}
@@ -114,7 +125,8 @@ func (r *StatsRepository) getTeamStats(ctx context.Context) (map[string]interfac
 			COUNT(DISTINCT team_name) as total_teams,
 			COUNT(*) as total_users
 		FROM users
-	`).Scan(&totalTeams, &totalUsers)
+		WHERE organization_id = $1
+	`, orgID(ctx)).Scan(&totalTeams, &totalUsers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get overall team stats: %w", err)
 	}
@@ -23,10 +23,12 @@ func (r *TeamRepository) CreateTeam(ctx context.Context, team *domain.Team) erro
 	}
 	defer tx.Rollback()
 
+	org := orgID(ctx)
+
 	var exists bool
 	err = tx.QueryRowContext(ctx,
-		"SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)",
-		team.TeamName).Scan(&exists)
+		"SELECT EXISTS(SELECT 1 FROM teams WHERE organization_id = $1 AND team_name = $2)",
+		org, team.TeamName).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check team existence: %w", err)
 	}
@@ -34,38 +36,70 @@ func (r *TeamRepository) CreateTeam(ctx context.Context, team *domain.Team) erro
 		return &domain.Error{Code: "TEAM_EXISTS", Message: "team already exists"}
 	}
 
-	_, err = tx.ExecContext(ctx,
-		"INSERT INTO teams (team_name) VALUES ($1)",
-		team.TeamName)
+	if team.ReviewerStrategy == "" {
+		team.ReviewerStrategy = domain.StrategyLeastLoaded
+	}
+	if team.ReviewersCount == 0 {
+		team.ReviewersCount = domain.DefaultReviewersCount
+	}
+	if team.OverloadPolicy == "" {
+		team.OverloadPolicy = domain.OverloadPolicyReject
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO teams (team_name, reviewer_strategy, reviewers_count, default_max_open_reviews, overload_policy, required_approvals, 
+			sla_reminder_hours, sla_escalation_hours, stale_after_days, stale_close_after_days, organization_id) 
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9, $10, $11)`,
+		team.TeamName, team.ReviewerStrategy, team.ReviewersCount, team.DefaultMaxOpenReviews, team.OverloadPolicy, team.RequiredApprovals,
+		team.SLAReminderHours, team.SLAEscalationHours, team.StaleAfterDays, team.StaleCloseAfterDays, org)
 	if err != nil {
 		return fmt.Errorf("failed to insert team: %w", err)
 	}
 
-	for _, member := range team.Members {
-		_, err = tx.ExecContext(ctx, `
-			INSERT INTO users (user_id, username, team_name, is_active) 
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (user_id) 
-			DO UPDATE SET username = $2, team_name = $3, is_active = $4, updated_at = CURRENT_TIMESTAMP`,
-			member.UserID, member.Username, team.TeamName, member.IsActive)
+	for i, member := range team.Members {
+		if member.ReviewWeight <= 0 {
+			member.ReviewWeight = 1
+			team.Members[i].ReviewWeight = 1
+		}
+		// Without an explicit role a user keeps theirs, except that a team
+		// lead moved to another team stops leading.
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO users (user_id, username, team_name, is_active, review_weight, max_open_reviews, role, organization_id) 
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), COALESCE(NULLIF($7, ''), 'member'), $8)
+			ON CONFLICT (organization_id, user_id) 
+			DO UPDATE SET username = $2, team_name = $3, is_active = $4, review_weight = $5, 
+				max_open_reviews = NULLIF($6, 0), updated_at = CURRENT_TIMESTAMP,
+				role = CASE
+					WHEN $7 <> '' THEN $7
+					WHEN users.role = 'team_lead' AND users.team_name <> $3 THEN 'member'
+					ELSE users.role
+				END
+			RETURNING role`,
+			member.UserID, member.Username, team.TeamName, member.IsActive, member.ReviewWeight, member.MaxOpenReviews, member.Role, org).Scan(&team.Members[i].Role)
 		if err != nil {
 			return fmt.Errorf("failed to upsert user %s: %w", member.UserID, err)
 		}
 	}
 
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionTeamAdd, domain.AuditEntityTeam, team.TeamName, nil, team); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
 func (r *TeamRepository) GetTeam(ctx context.Context, teamName string) (*domain.Team, error) {
-	var team domain.Team
-	team.TeamName = teamName
+	team, err := r.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
 
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT user_id, username, is_active 
+		SELECT user_id, username, is_active, review_weight, COALESCE(max_open_reviews, 0), role 
 		FROM users 
-		WHERE team_name = $1 
+		WHERE organization_id = $1 AND team_name = $2 
 		ORDER BY user_id`,
-		teamName)
+		orgID(ctx), teamName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query team members: %w", err)
 	}
@@ -73,7 +107,7 @@ func (r *TeamRepository) GetTeam(ctx context.Context, teamName string) (*domain.
 
 	for rows.Next() {
 		var member domain.TeamMember
-		if err := rows.Scan(&member.UserID, &member.Username, &member.IsActive); err != nil {
+		if err := rows.Scan(&member.UserID, &member.Username, &member.IsActive, &member.ReviewWeight, &member.MaxOpenReviews, &member.Role); err != nil {
 			return nil, fmt.Errorf("failed to scan team member: %w", err)
 		}
 		team.Members = append(team.Members, member)
@@ -83,5 +117,234 @@ func (r *TeamRepository) GetTeam(ctx context.Context, teamName string) (*domain.
 		return nil, &domain.Error{Code: "NOT_FOUND", Message: "team not found"}
 	}
 
+	team.FallbackTeams, err = r.GetFallbackTeams(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+const teamSettingsColumns = `reviewer_strategy, reviewers_count, COALESCE(default_max_open_reviews, 0), overload_policy, 
+	required_approvals, sla_reminder_hours, sla_escalation_hours, stale_after_days, stale_close_after_days`
+
+func scanTeamSettings(row *sql.Row, team *domain.Team) error {
+	return row.Scan(&team.ReviewerStrategy, &team.ReviewersCount, &team.DefaultMaxOpenReviews, &team.OverloadPolicy,
+		&team.RequiredApprovals, &team.SLAReminderHours, &team.SLAEscalationHours, &team.StaleAfterDays, &team.StaleCloseAfterDays)
+}
+
+// teamSettingsAudit lists every setting, including zero values that the JSON
+// of a team leaves out, so that e.g. dropping required_approvals to 0 shows.
+func teamSettingsAudit(team *domain.Team) map[string]interface{} {
+	return map[string]interface{}{
+		"reviewer_strategy":        team.ReviewerStrategy,
+		"reviewers_count":          team.ReviewersCount,
+		"default_max_open_reviews": team.DefaultMaxOpenReviews,
+		"overload_policy":          team.OverloadPolicy,
+		"required_approvals":       team.RequiredApprovals,
+		"sla_reminder_hours":       team.SLAReminderHours,
+		"sla_escalation_hours":     team.SLAEscalationHours,
+		"stale_after_days":         team.StaleAfterDays,
+		"stale_close_after_days":   team.StaleCloseAfterDays,
+	}
+}
+
+func (r *TeamRepository) GetTeamSettings(ctx context.Context, teamName string) (*domain.Team, error) {
+	team := domain.Team{TeamName: teamName}
+	err := scanTeamSettings(r.db.QueryRowContext(ctx, `
+		SELECT `+teamSettingsColumns+` 
+		FROM teams 
+		WHERE organization_id = $1 AND team_name = $2`,
+		orgID(ctx), teamName), &team)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "team not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team settings: %w", err)
+	}
 	return &team, nil
 }
+
+func (r *TeamRepository) UpdateTeamSettings(ctx context.Context, teamName string, settings domain.TeamSettingsUpdate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before := domain.Team{TeamName: teamName}
+	err = scanTeamSettings(tx.QueryRowContext(ctx, `
+		SELECT `+teamSettingsColumns+` 
+		FROM teams 
+		WHERE organization_id = $1 AND team_name = $2
+		FOR UPDATE`,
+		orgID(ctx), teamName), &before)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "team not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock team: %w", err)
+	}
+
+	query := "UPDATE teams SET updated_at = CURRENT_TIMESTAMP"
+	args := []interface{}{}
+
+	if settings.ReviewerStrategy != nil {
+		args = append(args, *settings.ReviewerStrategy)
+		query += fmt.Sprintf(", reviewer_strategy = $%d", len(args))
+	}
+	if settings.ReviewersCount != nil {
+		args = append(args, *settings.ReviewersCount)
+		query += fmt.Sprintf(", reviewers_count = $%d", len(args))
+	}
+	if settings.DefaultMaxOpenReviews != nil {
+		args = append(args, *settings.DefaultMaxOpenReviews)
+		query += fmt.Sprintf(", default_max_open_reviews = NULLIF($%d, 0)", len(args))
+	}
+	if settings.OverloadPolicy != nil {
+		args = append(args, *settings.OverloadPolicy)
+		query += fmt.Sprintf(", overload_policy = $%d", len(args))
+	}
+	if settings.RequiredApprovals != nil {
+		args = append(args, *settings.RequiredApprovals)
+		query += fmt.Sprintf(", required_approvals = $%d", len(args))
+	}
+	if settings.SLAReminderHours != nil {
+		args = append(args, *settings.SLAReminderHours)
+		query += fmt.Sprintf(", sla_reminder_hours = $%d", len(args))
+	}
+	if settings.SLAEscalationHours != nil {
+		args = append(args, *settings.SLAEscalationHours)
+		query += fmt.Sprintf(", sla_escalation_hours = $%d", len(args))
+	}
+	if settings.StaleAfterDays != nil {
+		args = append(args, *settings.StaleAfterDays)
+		query += fmt.Sprintf(", stale_after_days = $%d", len(args))
+	}
+	if settings.StaleCloseAfterDays != nil {
+		args = append(args, *settings.StaleCloseAfterDays)
+		query += fmt.Sprintf(", stale_close_after_days = $%d", len(args))
+	}
+
+	args = append(args, orgID(ctx), teamName)
+	query += fmt.Sprintf(" WHERE organization_id = $%d AND team_name = $%d", len(args)-1, len(args))
+	query += " RETURNING " + teamSettingsColumns
+
+	after := domain.Team{TeamName: teamName}
+	if err := scanTeamSettings(tx.QueryRowContext(ctx, query, args...), &after); err != nil {
+		return fmt.Errorf("failed to update team settings: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionTeamUpdateSettings, domain.AuditEntityTeam, teamName,
+		teamSettingsAudit(&before), teamSettingsAudit(&after))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TeamRepository) GetFallbackTeams(ctx context.Context, teamName string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT fallback_team_name 
+		FROM team_fallbacks 
+		WHERE organization_id = $1 AND team_name = $2 
+		ORDER BY priority`,
+		orgID(ctx), teamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fallback teams: %w", err)
+	}
+	defer rows.Close()
+
+	var fallbackTeams []string
+	for rows.Next() {
+		var fallbackTeam string
+		if err := rows.Scan(&fallbackTeam); err != nil {
+			return nil, fmt.Errorf("failed to scan fallback team: %w", err)
+		}
+		fallbackTeams = append(fallbackTeams, fallbackTeam)
+	}
+
+	return fallbackTeams, nil
+}
+
+func (r *TeamRepository) SetFallbackTeams(ctx context.Context, teamName string, fallbackTeams []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	org := orgID(ctx)
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM teams WHERE organization_id = $1 AND team_name = $2)",
+		org, teamName).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check team existence: %w", err)
+	}
+	if !exists {
+		return &domain.Error{Code: "NOT_FOUND", Message: "team not found"}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT fallback_team_name 
+		FROM team_fallbacks 
+		WHERE organization_id = $1 AND team_name = $2
+		ORDER BY priority`,
+		org, teamName)
+	if err != nil {
+		return fmt.Errorf("failed to get old fallback teams: %w", err)
+	}
+	previousTeams := []string{}
+	for rows.Next() {
+		var fallbackTeam string
+		if err := rows.Scan(&fallbackTeam); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan old fallback team: %w", err)
+		}
+		previousTeams = append(previousTeams, fallbackTeam)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get old fallback teams: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM team_fallbacks 
+		WHERE organization_id = $1 AND team_name = $2`,
+		org, teamName)
+	if err != nil {
+		return fmt.Errorf("failed to delete old fallback teams: %w", err)
+	}
+
+	for i, fallbackTeam := range fallbackTeams {
+		err = tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM teams WHERE organization_id = $1 AND team_name = $2)",
+			org, fallbackTeam).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check team existence: %w", err)
+		}
+		if !exists {
+			return &domain.Error{Code: "NOT_FOUND", Message: fmt.Sprintf("fallback team %s not found", fallbackTeam)}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO team_fallbacks (organization_id, team_name, fallback_team_name, priority)
+			VALUES ($1, $2, $3, $4)`,
+			org, teamName, fallbackTeam, i+1)
+		if err != nil {
+			return fmt.Errorf("failed to insert fallback team %s: %w", fallbackTeam, err)
+		}
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionTeamSetFallbacks, domain.AuditEntityTeam, teamName,
+		map[string][]string{"fallback_teams": previousTeams},
+		map[string][]string{"fallback_teams": nonNilStrings(fallbackTeams)})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/pavel/avitotech_previewer/internal/domain"
 )
 
@@ -17,14 +20,23 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 func (r *UserRepository) UpdateUserActive(ctx context.Context, userID string, isActive bool) (*domain.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var user domain.User
+	var wasActive bool
 
-	err := r.db.QueryRowContext(ctx, `
-		UPDATE users 
+	// Joining the row to itself exposes its pre-update value in RETURNING.
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users u
 		SET is_active = $1, updated_at = CURRENT_TIMESTAMP 
-		WHERE user_id = $2 
-		RETURNING user_id, username, team_name, is_active`,
-		isActive, userID).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive)
+		FROM users old
+		WHERE u.organization_id = $3 AND u.user_id = $2 AND old.id = u.id
+		RETURNING u.user_id, u.username, u.team_name, u.is_active, u.role, old.is_active`,
+		isActive, userID, orgID(ctx)).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive, &user.Role, &wasActive)
 
 	if err == sql.ErrNoRows {
 		return nil, &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
@@ -33,17 +45,207 @@ func (r *UserRepository) UpdateUserActive(ctx context.Context, userID string, is
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	if wasActive != user.IsActive {
+		eventType := domain.EventUserDeactivated
+		if user.IsActive {
+			eventType = domain.EventUserActivated
+		}
+
+		data := domain.UserStatusData{
+			UserID:   user.UserID,
+			TeamName: user.TeamName,
+			Reason:   domain.AssignmentReasonManual,
+		}
+		if err := insertOutboxEvent(ctx, tx, eventType, user.TeamName, []string{user.UserID}, data); err != nil {
+			return nil, err
+		}
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionUserSetActive, domain.AuditEntityUser, user.UserID,
+		map[string]bool{"is_active": wasActive}, map[string]bool{"is_active": user.IsActive})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateUserMaxOpenReviews(ctx context.Context, userID string, maxOpenReviews int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users u
+		SET max_open_reviews = NULLIF($1, 0), updated_at = CURRENT_TIMESTAMP 
+		FROM users old
+		WHERE u.organization_id = $3 AND u.user_id = $2 AND old.id = u.id
+		RETURNING COALESCE(old.max_open_reviews, 0)`,
+		maxOpenReviews, userID, orgID(ctx)).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update user capacity: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionUserSetMaxReviews, domain.AuditEntityUser, userID,
+		map[string]int{"max_open_reviews": previous}, map[string]int{"max_open_reviews": maxOpenReviews})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) UpdateUserRole(ctx context.Context, userID, role string) (*domain.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user domain.User
+	var previousRole string
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users u
+		SET role = $1, updated_at = CURRENT_TIMESTAMP
+		FROM users old
+		WHERE u.organization_id = $3 AND u.user_id = $2 AND old.id = u.id
+		RETURNING u.user_id, u.username, u.team_name, u.is_active, u.role, old.role`,
+		role, userID, orgID(ctx)).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive, &user.Role, &previousRole)
+	if err == sql.ErrNoRows {
+		return nil, &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionUserSetRole, domain.AuditEntityUser, user.UserID,
+		map[string]string{"role": previousRole}, map[string]string{"role": user.Role})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return &user, nil
 }
 
+func (r *UserRepository) UpdateUserEmailSettings(ctx context.Context, settings *domain.EmailSettings) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previousEmail, previousNotifications string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users u
+		SET email = NULLIF($1, ''), email_notifications = $2, updated_at = CURRENT_TIMESTAMP 
+		FROM users old
+		WHERE u.organization_id = $4 AND u.user_id = $3 AND old.id = u.id
+		RETURNING u.username, COALESCE(old.email, ''), old.email_notifications`,
+		settings.Email, settings.Notifications, settings.UserID, orgID(ctx)).Scan(&settings.Username, &previousEmail, &previousNotifications)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update user email settings: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionUserSetEmail, domain.AuditEntityUser, settings.UserID,
+		map[string]string{"email": previousEmail, "email_notifications": previousNotifications},
+		map[string]string{"email": settings.Email, "email_notifications": settings.Notifications})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetEmailRecipients returns those of userIDs who have an email address and
+// the given notification mode.
+func (r *UserRepository) GetEmailRecipients(ctx context.Context, userIDs []string, mode string) ([]domain.EmailSettings, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, username, email, email_notifications
+		FROM users
+		WHERE organization_id = $3 AND user_id = ANY($1) AND email IS NOT NULL AND email_notifications = $2
+		ORDER BY user_id`,
+		pq.Array(userIDs), mode, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email recipients: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEmailSettings(rows)
+}
+
+// GetDigestRecipients returns digest subscribers whose last digest was sent
+// before dueAt.
+func (r *UserRepository) GetDigestRecipients(ctx context.Context, dueAt time.Time, limit int) ([]domain.EmailSettings, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, username, email, email_notifications
+		FROM users
+		WHERE organization_id = $3 AND email_notifications = 'digest' AND email IS NOT NULL AND is_active = true
+			AND (email_digest_sent_at IS NULL OR email_digest_sent_at < $1)
+		ORDER BY user_id
+		LIMIT $2`,
+		dueAt, limit, orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest recipients: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEmailSettings(rows)
+}
+
+func (r *UserRepository) MarkDigestSent(ctx context.Context, userID string, sentAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users 
+		SET email_digest_sent_at = $1 
+		WHERE organization_id = $3 AND user_id = $2`,
+		sentAt, userID, orgID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to mark digest as sent: %w", err)
+	}
+	return nil
+}
+
+func scanEmailSettings(rows *sql.Rows) ([]domain.EmailSettings, error) {
+	var recipients []domain.EmailSettings
+	for rows.Next() {
+		var recipient domain.EmailSettings
+		if err := rows.Scan(&recipient.UserID, &recipient.Username, &recipient.Email, &recipient.Notifications); err != nil {
+			return nil, fmt.Errorf("failed to scan email recipient: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, rows.Err()
+}
+
 func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
 	var user domain.User
 
 	err := r.db.QueryRowContext(ctx, `
-		SELECT user_id, username, team_name, is_active 
+		SELECT user_id, username, team_name, is_active, role 
 		FROM users 
-		WHERE user_id = $1`,
-		userID).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive)
+		WHERE organization_id = $2 AND user_id = $1`,
+		userID, orgID(ctx)).Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive, &user.Role)
 
 	if err == sql.ErrNoRows {
 		return nil, &domain.Error{Code: "NOT_FOUND", Message: "user not found"}
@@ -56,41 +258,129 @@ func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*domai
 }
 
 func (r *UserRepository) BulkDeactivateUsers(ctx context.Context, teamName string, excludeUserIDs []string) (int64, error) {
-	query := "UPDATE users SET is_active = false, updated_at = CURRENT_TIMESTAMP WHERE team_name = $1"
-	args := []interface{}{teamName}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	activeBefore, err := lockActiveTeamUsers(ctx, tx, teamName)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE users u SET is_active = false, updated_at = CURRENT_TIMESTAMP 
+		FROM users old 
+		WHERE old.id = u.id AND u.organization_id = $1 AND u.team_name = $2`
+	args := []interface{}{orgID(ctx), teamName}
 
 	if len(excludeUserIDs) > 0 {
-		query += " AND user_id NOT IN ("
+		query += " AND u.user_id NOT IN ("
 		for i, id := range excludeUserIDs {
 			if i > 0 {
 				query += ","
 			}
-			query += fmt.Sprintf("$%d", i+2)
+			query += fmt.Sprintf("$%d", i+3)
 			args = append(args, id)
 		}
 		query += ")"
 	}
+	query += " RETURNING u.user_id, old.is_active"
 
-	result, err := r.db.ExecContext(ctx, query, args...)
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to deactivate users: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	var rowsAffected int64
+	var deactivated []string
+	for rows.Next() {
+		var userID string
+		var wasActive bool
+		if err := rows.Scan(&userID, &wasActive); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan deactivated user: %w", err)
+		}
+		rowsAffected++
+		if wasActive {
+			deactivated = append(deactivated, userID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to deactivate users: %w", err)
+	}
+
+	for _, userID := range deactivated {
+		data := domain.UserStatusData{
+			UserID:   userID,
+			TeamName: teamName,
+			Reason:   domain.AssignmentReasonBulkDeactivate,
+		}
+		if err := insertOutboxEvent(ctx, tx, domain.EventUserDeactivated, teamName, []string{userID}, data); err != nil {
+			return 0, err
+		}
+	}
+
+	activeAfter := make([]string, 0, len(activeBefore))
+	for _, userID := range activeBefore {
+		if !slices.Contains(deactivated, userID) {
+			activeAfter = append(activeAfter, userID)
+		}
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionBulkDeactivate, domain.AuditEntityTeam, teamName,
+		map[string][]string{"active_user_ids": activeBefore},
+		map[string][]string{
+			"active_user_ids":      activeAfter,
+			"deactivated_user_ids": nonNilStrings(deactivated),
+			"excluded_user_ids":    nonNilStrings(excludeUserIDs),
+		})
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return rowsAffected, nil
 }
 
+// lockActiveTeamUsers returns the active members of the team and locks their
+// rows, so the list stays accurate until the transaction ends.
+func lockActiveTeamUsers(ctx context.Context, tx *sql.Tx, teamName string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT user_id
+		FROM users
+		WHERE organization_id = $1 AND team_name = $2 AND is_active = true
+		ORDER BY user_id
+		FOR UPDATE`,
+		orgID(ctx), teamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock team users: %w", err)
+	}
+	defer rows.Close()
+
+	userIDs := make([]string, 0)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan team user: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
 func (r *UserRepository) GetTeamUsers(ctx context.Context, teamName string) ([]domain.User, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT user_id, username, team_name, is_active
+		SELECT user_id, username, team_name, is_active, role
 		FROM users 
-		WHERE team_name = $1
+		WHERE organization_id = $1 AND team_name = $2
 		ORDER BY user_id`,
-		teamName)
+		orgID(ctx), teamName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query team users: %w", err)
 	}
@@ -99,7 +389,7 @@ func (r *UserRepository) GetTeamUsers(ctx context.Context, teamName string) ([]d
 	var users []domain.User
 	for rows.Next() {
 		var user domain.User
-		if err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive); err != nil {
+		if err := rows.Scan(&user.UserID, &user.Username, &user.TeamName, &user.IsActive, &user.Role); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 		users = append(users, user)
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO webhook_subscriptions (url, secret, events, organization_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, is_active, created_at`,
+		sub.URL, sub.Secret, pq.Array(sub.Events), orgID(ctx)).Scan(&sub.ID, &sub.IsActive, &sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook subscription: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionSubscriptionAdd, domain.AuditEntitySubscription, strconv.Itoa(sub.ID), nil, sub); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, url, events, is_active, created_at
+		FROM webhook_subscriptions
+		WHERE organization_id = $1
+		ORDER BY id`,
+		orgID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]domain.WebhookSubscription, 0)
+	for rows.Next() {
+		var sub domain.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.URL, pq.Array(&sub.Events), &sub.IsActive, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, subscriptionID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sub domain.WebhookSubscription
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM webhook_subscriptions 
+		WHERE organization_id = $2 AND id = $1
+		RETURNING id, url, events, is_active, created_at`,
+		subscriptionID, orgID(ctx)).Scan(&sub.ID, &sub.URL, pq.Array(&sub.Events), &sub.IsActive, &sub.CreatedAt)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "subscription not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	if err := insertAuditEntry(ctx, tx, domain.AuditActionSubscriptionDelete, domain.AuditEntitySubscription, strconv.Itoa(sub.ID), &sub, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// EnqueueEvent creates one pending delivery per active subscription of the
+// organization whose event filter matches; an empty filter matches every
+// event.
+func (r *WebhookRepository) EnqueueEvent(ctx context.Context, eventType string, payload []byte) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_type, payload)
+		SELECT id, $1::varchar, $2::jsonb
+		FROM webhook_subscriptions
+		WHERE organization_id = $3 AND is_active = true AND (cardinality(events) = 0 OR $1 = ANY(events))`,
+		eventType, string(payload), orgID(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+type DueDelivery struct {
+	domain.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// ClaimDueDeliveries locks due deliveries and pushes their next attempt out by
+// lease, so a crashed sender's deliveries are retried and concurrent
+// instances do not pick up the same rows. Deliveries of all organizations are
+// claimed together.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'PENDING' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.subscription_id, d.event_type, d.payload, d.attempts, s.url, s.secret`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, deliveryID int64, statusCode int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'DELIVERED', attempts = attempts + 1, last_status_code = $2,
+			last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		deliveryID, statusCode)
+	if err != nil {
+		return fmt.Errorf("failed to mark delivery as delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkAttemptFailed records a failed attempt. A nil nextAttemptAt means the
+// retry budget is spent and the delivery becomes FAILED.
+func (r *WebhookRepository) MarkAttemptFailed(ctx context.Context, deliveryID int64, statusCode int, errMsg string, nextAttemptAt *time.Time) error {
+	status := domain.DeliveryStatusPending
+	if nextAttemptAt == nil {
+		status = domain.DeliveryStatusFailed
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_status_code = NULLIF($3, 0),
+			last_error = $4, next_attempt_at = COALESCE($5, next_attempt_at)
+		WHERE id = $1`,
+		deliveryID, status, statusCode, errMsg, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) GetDeliveries(ctx context.Context, subscriptionID int, status string, limit int) ([]domain.WebhookDelivery, error) {
+	query := `
+		SELECT d.id, d.subscription_id, d.event_type, d.status, d.attempts, d.next_attempt_at,
+			d.last_status_code, d.last_error, d.delivered_at, d.created_at
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE s.organization_id = $1`
+	args := []interface{}{orgID(ctx)}
+
+	if subscriptionID > 0 {
+		args = append(args, subscriptionID)
+		query += fmt.Sprintf(" AND d.subscription_id = $%d", len(args))
+	}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND d.status = $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY d.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Redeliver puts a delivery back into the queue with a fresh retry budget.
+func (r *WebhookRepository) Redeliver(ctx context.Context, deliveryID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var subscriptionID, previousAttempts int
+	var previousStatus string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'PENDING', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, delivered_at = NULL
+		FROM webhook_subscriptions s, webhook_deliveries old
+		WHERE s.id = webhook_deliveries.subscription_id AND s.organization_id = $2
+			AND webhook_deliveries.id = $1 AND old.id = webhook_deliveries.id
+		RETURNING webhook_deliveries.subscription_id, old.status, old.attempts`,
+		deliveryID, orgID(ctx)).Scan(&subscriptionID, &previousStatus, &previousAttempts)
+	if err == sql.ErrNoRows {
+		return &domain.Error{Code: "NOT_FOUND", Message: "delivery not found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to requeue delivery: %w", err)
+	}
+
+	err = insertAuditEntry(ctx, tx, domain.AuditActionDeliveryRedeliver, domain.AuditEntityDelivery, strconv.FormatInt(deliveryID, 10),
+		map[string]interface{}{"subscription_id": subscriptionID, "status": previousStatus, "attempts": previousAttempts},
+		map[string]interface{}{"subscription_id": subscriptionID, "status": domain.DeliveryStatusPending, "attempts": 0})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+type AbsenceService struct {
+	absenceRepo *repository.AbsenceRepository
+	userRepo    *repository.UserRepository
+	prRepo      *repository.PullRequestRepository
+	prService   *PullRequestService
+}
+
+func NewAbsenceService(absenceRepo *repository.AbsenceRepository, userRepo *repository.UserRepository, prRepo *repository.PullRequestRepository, prService *PullRequestService) *AbsenceService {
+	return &AbsenceService{
+		absenceRepo: absenceRepo,
+		userRepo:    userRepo,
+		prRepo:      prRepo,
+		prService:   prService,
+	}
+}
+
+func (s *AbsenceService) AddAbsence(ctx context.Context, absence *domain.Absence) error {
+	if !absence.EndsAt.After(absence.StartsAt) {
+		return &domain.Error{Code: "INVALID_PERIOD", Message: "ends_at must be after starts_at"}
+	}
+	if !absence.EndsAt.After(time.Now()) {
+		return &domain.Error{Code: "INVALID_PERIOD", Message: "absence period is already over"}
+	}
+
+	if _, err := s.userRepo.GetUserByID(ctx, absence.UserID); err != nil {
+		return err
+	}
+
+	if err := s.absenceRepo.CreateAbsence(ctx, absence); err != nil {
+		return err
+	}
+
+	if absence.IsActiveAt(time.Now()) {
+		if err := s.reassignAbsentReviewer(ctx, *absence); err != nil {
+			log.Printf("Failed to reassign reviews for absence %d: %v", absence.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *AbsenceService) GetUserAbsences(ctx context.Context, userID string) ([]domain.Absence, error) {
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.absenceRepo.GetUserAbsences(ctx, userID)
+}
+
+func (s *AbsenceService) GetAbsence(ctx context.Context, absenceID int) (*domain.Absence, error) {
+	return s.absenceRepo.GetAbsence(ctx, absenceID)
+}
+
+func (s *AbsenceService) DeleteAbsence(ctx context.Context, absenceID int) error {
+	return s.absenceRepo.DeleteAbsence(ctx, absenceID)
+}
+
+// ProcessStartedAbsences hands over open reviews of users whose absence has
+// just begun. Each absence is processed once.
+func (s *AbsenceService) ProcessStartedAbsences(ctx context.Context) error {
+	absences, err := s.absenceRepo.GetStartedAbsences(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, absence := range absences {
+		if err := s.reassignAbsentReviewer(ctx, absence); err != nil {
+			log.Printf("Failed to reassign reviews for absence %d: %v", absence.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reassignAbsentReviewer hands every open review of the absent user over to
+// someone else. The absence is marked processed only once all of them are
+// handed over; otherwise the worker retries the remaining ones on its next
+// run until the absence ends.
+func (s *AbsenceService) reassignAbsentReviewer(ctx context.Context, absence domain.Absence) error {
+	prIDs, err := s.prRepo.GetOpenPRsWithReviewer(ctx, absence.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get PRs for user %s: %w", absence.UserID, err)
+	}
+
+	failed := 0
+	for _, prID := range prIDs {
+		reassigned, err := s.prService.ReassignReviewer(ctx, prID, absence.UserID, domain.AssignmentReasonReassign)
+		if err != nil {
+			log.Printf("Failed to reassign PR %s from absent user %s: %v", prID, absence.UserID, err)
+			failed++
+			continue
+		}
+		log.Printf("Reassigned PR %s from absent user %s to %s", prID, absence.UserID, reassigned.NewReviewerID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d PRs of user %s are not reassigned yet, will retry", failed, len(prIDs), absence.UserID)
+	}
+
+	return s.absenceRepo.MarkReviewsReassigned(ctx, absence.ID)
+}
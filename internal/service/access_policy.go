@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+// AccessPolicy decides who may change teams, users and pull requests. Scopes
+// only say which kind of call a credential may make; the policy looks at who
+// the caller is. Personal tokens act with the role of their user. API keys
+// act for a service and have no role: a credential with the admin scope is
+// treated as an admin, and a key with the write scope may change any team,
+// user and PR of its organization, but not create teams, assign roles,
+// override merges or review in someone's name.
+type AccessPolicy struct {
+	userRepo *repository.UserRepository
+	prRepo   *repository.PullRequestRepository
+}
+
+func NewAccessPolicy(userRepo *repository.UserRepository, prRepo *repository.PullRequestRepository) *AccessPolicy {
+	return &AccessPolicy{
+		userRepo: userRepo,
+		prRepo:   prRepo,
+	}
+}
+
+type principal struct {
+	userID   string
+	role     string
+	teamName string
+	service  bool
+}
+
+func (p principal) isAdmin() bool {
+	return p.role == domain.RoleAdmin
+}
+
+// managesAll tells whether the caller may change any team, user and PR.
+func (p principal) managesAll() bool {
+	return p.isAdmin() || p.service
+}
+
+func (p principal) isUser(userID string) bool {
+	return p.userID != "" && p.userID == userID
+}
+
+func (p principal) leads(teamName string) bool {
+	return p.role == domain.RoleTeamLead && p.teamName == teamName
+}
+
+// roleRanks orders roles so that nobody acts on someone above them.
+var roleRanks = map[string]int{
+	domain.RoleMember:   0,
+	domain.RoleTeamLead: 1,
+	domain.RoleAdmin:    2,
+}
+
+func (s *AccessPolicy) resolve(ctx context.Context, identity *domain.Identity) (*principal, error) {
+	if identity == nil {
+		return nil, forbidden("authentication required")
+	}
+	if identity.HasScope(domain.ScopeAdmin) {
+		return &principal{userID: identity.UserID, role: domain.RoleAdmin}, nil
+	}
+	if identity.UserID == "" {
+		return &principal{service: identity.HasScope(domain.ScopeWrite)}, nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, identity.UserID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			return nil, forbidden("caller is not a known user")
+		}
+		return nil, err
+	}
+	return &principal{userID: user.UserID, role: user.Role, teamName: user.TeamName}, nil
+}
+
+func (s *AccessPolicy) CanCreateTeam(ctx context.Context, identity *domain.Identity) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if !caller.isAdmin() {
+		return forbidden("only admins may create teams")
+	}
+	return nil
+}
+
+// CanManageTeam covers team settings and bulk deactivation.
+func (s *AccessPolicy) CanManageTeam(ctx context.Context, identity *domain.Identity, teamName string) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if !caller.managesAll() && !caller.leads(teamName) {
+		return forbidden("only admins and the team lead may manage team " + teamName)
+	}
+	return nil
+}
+
+// OutrankingMembers returns the members of the team whose role is above the
+// caller's. Bulk changes to the team leave them alone, so that a lead cannot
+// deactivate an admin of their team.
+func (s *AccessPolicy) OutrankingMembers(ctx context.Context, identity *domain.Identity, teamName string) ([]string, error) {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if caller.managesAll() {
+		return nil, nil
+	}
+
+	users, err := s.userRepo.GetTeamUsers(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []string
+	for _, user := range users {
+		if roleRanks[user.Role] > roleRanks[caller.role] {
+			userIDs = append(userIDs, user.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+// CanManageUser covers changes a user may not make on their own, such as
+// deactivation.
+func (s *AccessPolicy) CanManageUser(ctx context.Context, identity *domain.Identity, userID string) error {
+	return s.checkUser(ctx, identity, userID, false)
+}
+
+// CanEditUser covers personal settings, which users may also change
+// themselves.
+func (s *AccessPolicy) CanEditUser(ctx context.Context, identity *domain.Identity, userID string) error {
+	return s.checkUser(ctx, identity, userID, true)
+}
+
+func (s *AccessPolicy) checkUser(ctx context.Context, identity *domain.Identity, userID string, allowSelf bool) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if caller.managesAll() || (allowSelf && caller.isUser(userID)) {
+		return nil
+	}
+	leads, err := s.leadsUser(ctx, caller, userID)
+	if err != nil || leads {
+		return err
+	}
+	return forbidden("only admins, the team lead or the user may change user " + userID)
+}
+
+// leadsUser tells whether the caller leads the user's team and the user's
+// role does not outrank theirs, so that a lead cannot act on an admin.
+func (s *AccessPolicy) leadsUser(ctx context.Context, caller *principal, userID string) (bool, error) {
+	if caller.role != domain.RoleTeamLead {
+		return false, nil
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return caller.leads(user.TeamName) && roleRanks[user.Role] <= roleRanks[caller.role], nil
+}
+
+func (s *AccessPolicy) CanSetRole(ctx context.Context, identity *domain.Identity) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if !caller.isAdmin() {
+		return forbidden("only admins may assign roles")
+	}
+	return nil
+}
+
+// CanCreatePR lets users open PRs as themselves; admins and the lead of the
+// author's team may open them on the author's behalf.
+func (s *AccessPolicy) CanCreatePR(ctx context.Context, identity *domain.Identity, authorID string) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	return s.checkAuthor(ctx, caller, authorID)
+}
+
+// CanChangePRStatus covers closing, reopening and marking a draft ready,
+// which are up to the author, the lead of the author's team and admins.
+func (s *AccessPolicy) CanChangePRStatus(ctx context.Context, identity *domain.Identity, prID string) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if caller.managesAll() {
+		return nil
+	}
+
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return err
+	}
+	return s.checkAuthor(ctx, caller, pr.AuthorID)
+}
+
+func (s *AccessPolicy) checkAuthor(ctx context.Context, caller *principal, authorID string) error {
+	if caller.managesAll() || caller.isUser(authorID) {
+		return nil
+	}
+	leads, err := s.leadsUser(ctx, caller, authorID)
+	if err != nil || leads {
+		return err
+	}
+	return forbidden("only admins, the author or the author's team lead may act on PRs of " + authorID)
+}
+
+// CanReassign lets the author and the reviewers of a PR hand a review over.
+func (s *AccessPolicy) CanReassign(ctx context.Context, identity *domain.Identity, prID string) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if caller.managesAll() {
+		return nil
+	}
+
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return err
+	}
+	if caller.userID != "" {
+		if pr.AuthorID == caller.userID {
+			return nil
+		}
+		for _, reviewerID := range pr.AssignedReviewers {
+			if reviewerID == caller.userID {
+				return nil
+			}
+		}
+	}
+	return forbidden("only the author or an assigned reviewer may reassign reviewers")
+}
+
+// CanReview keeps users from submitting reviews on behalf of someone else.
+func (s *AccessPolicy) CanReview(ctx context.Context, identity *domain.Identity, reviewerID string) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if !caller.isAdmin() && (caller.userID == "" || caller.userID != reviewerID) {
+		return forbidden("reviews can only be submitted by the reviewer")
+	}
+	return nil
+}
+
+func (s *AccessPolicy) CanOverrideMerge(ctx context.Context, identity *domain.Identity) error {
+	caller, err := s.resolve(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if !caller.isAdmin() {
+		return forbidden("only admins may override the approval policy")
+	}
+	return nil
+}
+
+func forbidden(message string) error {
+	return &domain.Error{Code: "FORBIDDEN", Message: message}
+}
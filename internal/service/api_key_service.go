@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/config"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const (
+	apiKeyPrefix       = "prv_"
+	apiKeyPrefixLength = 12
+	// apiKeyUsageResolution limits how often last_used_at is written for a
+	// key that is used on every request.
+	apiKeyUsageResolution = time.Minute
+)
+
+type APIKeyService struct {
+	apiKeyRepo   *repository.APIKeyRepository
+	bootstrapKey string
+}
+
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, cfg config.AuthConfig) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:   apiKeyRepo,
+		bootstrapKey: cfg.BootstrapKey,
+	}
+}
+
+// IssueKey creates a key with the given scopes and returns it together with
+// the plaintext secret. Only the hash is stored, so the secret cannot be
+// recovered later.
+func (s *APIKeyService) IssueKey(ctx context.Context, name string, scopes []string) (*domain.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", &domain.Error{
+			Code:    "INVALID_SCOPE",
+			Message: "at least one scope is required",
+			Details: map[string]interface{}{"allowed": domain.APIKeyScopes},
+		}
+	}
+	for _, scope := range scopes {
+		if !domain.IsValidScope(scope) {
+			return nil, "", &domain.Error{
+				Code:    "INVALID_SCOPE",
+				Message: fmt.Sprintf("unknown scope %q", scope),
+				Details: map[string]interface{}{"allowed": domain.APIKeyScopes},
+			}
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plaintext := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &domain.APIKey{
+		Name:   name,
+		Prefix: plaintext[:apiKeyPrefixLength],
+		Scopes: scopes,
+	}
+	if err := s.apiKeyRepo.CreateAPIKey(ctx, key, hashAPIKey(plaintext)); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.apiKeyRepo.ListAPIKeys(ctx)
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, keyID int) (*domain.APIKey, error) {
+	return s.apiKeyRepo.RevokeAPIKey(ctx, keyID)
+}
+
+// Authenticate resolves a presented key. The bootstrap key from the config
+// is accepted as an admin key so that the first real keys can be issued.
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext string) (*domain.APIKey, error) {
+	if plaintext == "" {
+		return nil, &domain.Error{Code: "UNAUTHORIZED", Message: "API key is required"}
+	}
+
+	if s.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(plaintext), []byte(s.bootstrapKey)) == 1 {
+		return &domain.APIKey{Name: "bootstrap", Scopes: []string{domain.ScopeAdmin}}, nil
+	}
+
+	key, err := s.apiKeyRepo.GetActiveAPIKey(ctx, hashAPIKey(plaintext))
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			return nil, &domain.Error{Code: "UNAUTHORIZED", Message: "invalid or revoked API key"}
+		}
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyUsageResolution {
+		if err := s.apiKeyRepo.MarkAPIKeyUsed(ctx, key.ID); err != nil {
+			log.Printf("Failed to record usage of API key %d: %v", key.ID, err)
+		}
+	}
+
+	return key, nil
+}
+
+// hashAPIKey uses a plain SHA-256: keys are 256 random bits, so a slow
+// password hash would add latency to every request without adding security.
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -63,7 +63,7 @@ func (s *BulkDeactivationService) reassignPRsForDeactivatedUsers(ctx context.Con
 
 		for _, prID := range prIDs {
 
-			newReviewer, err := s.prService.ReassignReviewer(ctx, prID, user.UserID)
+			reassigned, err := s.prService.ReassignReviewer(ctx, prID, user.UserID, domain.AssignmentReasonBulkDeactivate)
 			if err != nil {
 				fmt.Printf("Failed to reassign PR %s from user %s: %v\n", prID, user.UserID, err)
 				continue
@@ -72,7 +72,7 @@ func (s *BulkDeactivationService) reassignPRsForDeactivatedUsers(ctx context.Con
 			reassignedPRs = append(reassignedPRs, ReassignedPR{
 				PRID:        prID,
 				OldReviewer: user.UserID,
-				NewReviewer: newReviewer,
+				NewReviewer: reassigned.NewReviewerID,
 			})
 		}
 	}
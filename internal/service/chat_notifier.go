@@ -0,0 +1,315 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const (
+	DefaultAssignedTemplate   = `Review requested: *{{.PullRequestName}}* ({{.PullRequestID}}) by {{mention .AuthorID}} - {{mentions .Reviewers}}`
+	DefaultReassignedTemplate = `{{mention .NewReviewerID}} replaces {{mention .OldReviewerID}} as reviewer of *{{.PullRequestName}}* ({{.PullRequestID}}), reason: {{.Reason}}`
+)
+
+// ChatMessageData is what chat templates are rendered with.
+type ChatMessageData struct {
+	Event           string
+	TeamName        string
+	PullRequestID   string
+	PullRequestName string
+	AuthorID        string
+	Reviewers       []string
+	OldReviewerID   string
+	NewReviewerID   string
+	BorrowedFrom    string
+	Reason          string
+}
+
+// ChatMessage is the Slack/Mattermost incoming webhook payload.
+type ChatMessage struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+type ChatPreviewRequest struct {
+	EventType     string
+	PullRequestID string
+	OldReviewerID string
+	NewReviewerID string
+	Template      string
+}
+
+type ChatNotifier struct {
+	settingsRepo *repository.ChatSettingsRepository
+	identityRepo *repository.ExternalIdentityRepository
+	prRepo       *repository.PullRequestRepository
+	client       *http.Client
+}
+
+func NewChatNotifier(settingsRepo *repository.ChatSettingsRepository, identityRepo *repository.ExternalIdentityRepository, prRepo *repository.PullRequestRepository, timeout time.Duration) *ChatNotifier {
+	return &ChatNotifier{
+		settingsRepo: settingsRepo,
+		identityRepo: identityRepo,
+		prRepo:       prRepo,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *ChatNotifier) SetTeamSettings(ctx context.Context, settings *domain.TeamChatSettings) error {
+	target, err := url.Parse(settings.WebhookURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return &domain.Error{Code: "INVALID_URL", Message: "webhook_url must be an absolute http(s) URL"}
+	}
+
+	for _, text := range []string{settings.AssignedTemplate, settings.ReassignedTemplate} {
+		if text == "" {
+			continue
+		}
+		// Executing against sample data also catches unknown fields, which
+		// parsing alone lets through.
+		tmpl, err := parseChatTemplate(text, nil)
+		if err == nil {
+			err = tmpl.Execute(io.Discard, &ChatMessageData{Reviewers: []string{}})
+		}
+		if err != nil {
+			return &domain.Error{Code: "INVALID_TEMPLATE", Message: err.Error()}
+		}
+	}
+
+	return n.settingsRepo.UpsertTeamChatSettings(ctx, settings)
+}
+
+func (n *ChatNotifier) GetTeamSettings(ctx context.Context, teamName string) (*domain.TeamChatSettings, error) {
+	return n.settingsRepo.GetTeamChatSettings(ctx, teamName)
+}
+
+// Preview renders the message a real event would produce without sending it.
+// A template in the request takes precedence over the team's one.
+func (n *ChatNotifier) Preview(ctx context.Context, req ChatPreviewRequest) (*ChatMessage, error) {
+	if req.EventType != domain.EventReviewersAssigned && req.EventType != domain.EventReviewerReassigned {
+		return nil, &domain.Error{Code: "INVALID_EVENT", Message: "event_type must be pr.reviewers_assigned or pr.reviewer_reassigned"}
+	}
+
+	data, pr, err := n.messageData(ctx, req.EventType, req.PullRequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.EventType {
+	case domain.EventReviewersAssigned:
+		data.Reviewers = pr.AssignedReviewers
+		data.Reason = domain.AssignmentReasonCreate
+	case domain.EventReviewerReassigned:
+		if req.OldReviewerID == "" || req.NewReviewerID == "" {
+			return nil, &domain.Error{Code: "MISSING_PARAMETER", Message: "old_reviewer_id and new_reviewer_id are required for reassignment"}
+		}
+		data.OldReviewerID = req.OldReviewerID
+		data.NewReviewerID = req.NewReviewerID
+		data.Reason = domain.AssignmentReasonManual
+	}
+
+	settings, err := n.settingsRepo.GetTeamChatSettings(ctx, data.TeamName)
+	if err != nil && !domain.IsDomainError(err, "NOT_FOUND") {
+		return nil, err
+	}
+
+	text := req.Template
+	if text == "" {
+		text = templateFor(settings, req.EventType)
+	}
+
+	message, err := n.render(ctx, text, data)
+	if err != nil {
+		return nil, &domain.Error{Code: "INVALID_TEMPLATE", Message: err.Error()}
+	}
+	if settings != nil {
+		message.Channel = settings.Channel
+	}
+	return message, nil
+}
+
+func (n *ChatNotifier) Name() string {
+	return "chat"
+}
+
+// Publish posts assignment and reassignment events to the PR team's chat.
+// Notifications are best effort: a failing chat webhook is logged instead of
+// returned, so it does not hold back the outbox for the other sinks.
+func (n *ChatNotifier) Publish(ctx context.Context, event domain.Event) error {
+	if event.Type != domain.EventReviewersAssigned && event.Type != domain.EventReviewerReassigned {
+		return nil
+	}
+
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	var prID string
+	var assigned domain.ReviewersAssignedData
+	var reassigned domain.ReviewerReassignedData
+	if event.Type == domain.EventReviewersAssigned {
+		err = json.Unmarshal(raw, &assigned)
+		prID = assigned.PullRequestID
+	} else {
+		err = json.Unmarshal(raw, &reassigned)
+		prID = reassigned.PullRequestID
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+
+	data, _, err := n.messageData(ctx, event.Type, prID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			return nil
+		}
+		return err
+	}
+
+	if event.Type == domain.EventReviewersAssigned {
+		data.Reviewers = assigned.Reviewers
+		data.Reason = assigned.Reason
+	} else {
+		data.OldReviewerID = reassigned.OldReviewerID
+		data.NewReviewerID = reassigned.NewReviewerID
+		data.BorrowedFrom = reassigned.BorrowedFrom
+		data.Reason = reassigned.Reason
+	}
+
+	settings, err := n.settingsRepo.GetTeamChatSettings(ctx, data.TeamName)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			return nil
+		}
+		return err
+	}
+	if !settings.IsEnabled {
+		return nil
+	}
+
+	message, err := n.render(ctx, templateFor(settings, event.Type), data)
+	if err != nil {
+		log.Printf("Failed to render chat message for team %s: %v", data.TeamName, err)
+		return nil
+	}
+	message.Channel = settings.Channel
+
+	if err := n.send(ctx, settings.WebhookURL, message); err != nil {
+		log.Printf("Failed to notify team %s about event %d: %v", data.TeamName, event.ID, err)
+	}
+	return nil
+}
+
+func (n *ChatNotifier) messageData(ctx context.Context, eventType, prID string) (*ChatMessageData, *domain.PullRequest, error) {
+	pr, err := n.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teamName, err := n.prRepo.GetUserTeam(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := &ChatMessageData{
+		Event:           eventType,
+		TeamName:        teamName,
+		PullRequestID:   pr.PullRequestID,
+		PullRequestName: pr.PullRequestName,
+		AuthorID:        pr.AuthorID,
+	}
+	return data, pr, nil
+}
+
+func (n *ChatNotifier) render(ctx context.Context, text string, data *ChatMessageData) (*ChatMessage, error) {
+	userIDs := append([]string{data.AuthorID, data.OldReviewerID, data.NewReviewerID}, data.Reviewers...)
+	handles, err := n.identityRepo.GetExternalLogins(ctx, domain.ProviderChat, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := parseChatTemplate(text, handles)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return &ChatMessage{Text: out.String()}, nil
+}
+
+func (n *ChatNotifier) send(ctx context.Context, webhookURL string, message *ChatMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("chat webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func templateFor(settings *domain.TeamChatSettings, eventType string) string {
+	if eventType == domain.EventReviewerReassigned {
+		if settings != nil && settings.ReassignedTemplate != "" {
+			return settings.ReassignedTemplate
+		}
+		return DefaultReassignedTemplate
+	}
+
+	if settings != nil && settings.AssignedTemplate != "" {
+		return settings.AssignedTemplate
+	}
+	return DefaultAssignedTemplate
+}
+
+// parseChatTemplate parses a message template. Templates can use
+// {{mention .AuthorID}} and {{mentions .Reviewers}}, which render users as
+// @handle when they have a "chat" identity and as their user_id otherwise.
+func parseChatTemplate(text string, handles map[string]string) (*template.Template, error) {
+	mention := func(userID string) string {
+		if handle, ok := handles[userID]; ok {
+			return "@" + handle
+		}
+		return userID
+	}
+
+	return template.New("chat").Funcs(template.FuncMap{
+		"mention": mention,
+		"mentions": func(userIDs []string) string {
+			mentioned := make([]string, len(userIDs))
+			for i, userID := range userIDs {
+				mentioned[i] = mention(userID)
+			}
+			return strings.Join(mentioned, ", ")
+		},
+	}).Parse(text)
+}
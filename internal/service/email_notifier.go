@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/config"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const emailDigestBatchSize = 100
+
+type EmailNotifier struct {
+	userRepo *repository.UserRepository
+	prRepo   *repository.PullRequestRepository
+	cfg      config.SMTPConfig
+}
+
+func NewEmailNotifier(userRepo *repository.UserRepository, prRepo *repository.PullRequestRepository, cfg config.SMTPConfig) *EmailNotifier {
+	return &EmailNotifier{
+		userRepo: userRepo,
+		prRepo:   prRepo,
+		cfg:      cfg,
+	}
+}
+
+func (n *EmailNotifier) SetEmailSettings(ctx context.Context, settings *domain.EmailSettings) error {
+	if !domain.IsValidEmailNotifications(settings.Notifications) {
+		return &domain.Error{Code: "INVALID_MODE", Message: "email_notifications must be off, immediate or digest"}
+	}
+
+	if settings.Email != "" {
+		address, err := mail.ParseAddress(settings.Email)
+		if err != nil || address.Address != settings.Email {
+			return &domain.Error{Code: "INVALID_EMAIL", Message: "email must be a plain address like name@example.com"}
+		}
+	} else if settings.Notifications != domain.EmailNotificationsOff {
+		return &domain.Error{Code: "INVALID_EMAIL", Message: "email is required to enable notifications"}
+	}
+
+	return n.userRepo.UpdateUserEmailSettings(ctx, settings)
+}
+
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+// Publish emails newly assigned reviewers who chose immediate notifications.
+// Rejected recipients are only logged; if the server cannot be reached or
+// fails temporarily, the event is retried on this sink.
+func (n *EmailNotifier) Publish(ctx context.Context, event domain.Event) error {
+	var prID string
+	var reviewerIDs []string
+
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case domain.EventReviewersAssigned:
+		var data domain.ReviewersAssignedData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+		}
+		prID, reviewerIDs = data.PullRequestID, data.Reviewers
+	case domain.EventReviewerReassigned:
+		var data domain.ReviewerReassignedData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+		}
+		prID, reviewerIDs = data.PullRequestID, []string{data.NewReviewerID}
+	default:
+		return nil
+	}
+
+	recipients, err := n.userRepo.GetEmailRecipients(ctx, reviewerIDs, domain.EmailNotificationsImmediate)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	pr, err := n.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		if domain.IsDomainError(err, "NOT_FOUND") {
+			return nil
+		}
+		return err
+	}
+
+	subject := fmt.Sprintf("Review requested: %s", pr.PullRequestName)
+	var errs []error
+	for _, recipient := range recipients {
+		body := fmt.Sprintf("Hi %s,\n\nyou have been assigned to review %q (%s) by %s.\n",
+			recipient.Username, pr.PullRequestName, pr.PullRequestID, pr.AuthorID)
+		if err := n.send(ctx, recipient.Email, subject, body); err != nil {
+			if isPermanentSMTPError(err) {
+				log.Printf("Failed to email %s about PR %s: %v", recipient.UserID, prID, err)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to email %s: %w", recipient.UserID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ProcessDigests sends the daily digest of open reviews to every digest
+// subscriber who has not received one since today's digest hour (UTC).
+func (n *EmailNotifier) ProcessDigests(ctx context.Context) error {
+	if n.cfg.Host == "" {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	dueAt := time.Date(now.Year(), now.Month(), now.Day(), n.cfg.DigestHour, 0, 0, 0, time.UTC)
+	if now.Before(dueAt) {
+		dueAt = dueAt.AddDate(0, 0, -1)
+	}
+
+	recipients, err := n.userRepo.GetDigestRecipients(ctx, dueAt, emailDigestBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		if err := n.sendDigest(ctx, recipient); err != nil {
+			log.Printf("Failed to send review digest to %s: %v", recipient.UserID, err)
+			continue
+		}
+		if err := n.userRepo.MarkDigestSent(ctx, recipient.UserID, now); err != nil {
+			log.Printf("Failed to mark review digest for %s: %v", recipient.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+func (n *EmailNotifier) sendDigest(ctx context.Context, recipient domain.EmailSettings) error {
+	prs, err := n.prRepo.GetUserReviewPRs(ctx, recipient.UserID)
+	if err != nil {
+		return err
+	}
+
+	var open []domain.PullRequestShort
+	for _, pr := range prs {
+		if pr.Status == domain.PRStatusOpen {
+			open = append(open, pr)
+		}
+	}
+	// Nothing to review means nothing to send; the digest still counts as done.
+	if len(open) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\nyou have %d open review(s):\n\n", recipient.Username, len(open))
+	for _, pr := range open {
+		fmt.Fprintf(&body, "- %s (%s) by %s: %s\n", pr.PullRequestName, pr.PullRequestID, pr.AuthorID, pr.ReviewState)
+	}
+
+	return n.send(ctx, recipient.Email, fmt.Sprintf("Your open reviews: %d", len(open)), body.String())
+}
+
+// send delivers one message within the SMTP timeout. The deadline covers the
+// whole exchange, so a server that accepts the connection and then stalls
+// cannot hold up the relay.
+func (n *EmailNotifier) send(ctx context.Context, to, subject, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	ctx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: n.cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.cfg.Host, n.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set SMTP deadline: %w", err)
+	}
+	// Closing the connection aborts a pending read or write on cancellation.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(n.cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// isPermanentSMTPError tells rejections a retry cannot fix, such as an
+// unknown mailbox, from timeouts and temporary (4xx) failures.
+func isPermanentSMTPError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const eventStreamBatchSize = 100
+
+type EventFilter struct {
+	TeamName string
+	UserID   string
+}
+
+type EventStreamService struct {
+	outboxRepo *repository.OutboxRepository
+}
+
+func NewEventStreamService(outboxRepo *repository.OutboxRepository) *EventStreamService {
+	return &EventStreamService{outboxRepo: outboxRepo}
+}
+
+func (s *EventStreamService) CurrentCursor(ctx context.Context) (domain.EventCursor, error) {
+	return s.outboxRepo.CurrentCursor(ctx)
+}
+
+func (s *EventStreamService) EventsAfter(ctx context.Context, cursor domain.EventCursor, filter EventFilter) ([]repository.StreamEvent, error) {
+	return s.outboxRepo.GetEventsAfter(ctx, cursor, filter.TeamName, filter.UserID, eventStreamBatchSize)
+}
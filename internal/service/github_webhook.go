@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type GitHubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Draft  bool   `json:"draft"`
+		Merged bool   `json:"merged"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func (e *GitHubPullRequestEvent) PullRequestID() string {
+	return fmt.Sprintf("github:%s#%d", e.Repository.FullName, e.PullRequest.Number)
+}
+
+// VerifyGitHubSignature checks the X-Hub-Signature-256 header against the
+// HMAC-SHA256 of the raw request body.
+func VerifyGitHubSignature(secret string, body []byte, signature string) bool {
+	const prefix = "sha256="
+	if secret == "" || !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func (s *IntegrationService) HandleGitHubPullRequest(ctx context.Context, event *GitHubPullRequestEvent) (*WebhookResult, error) {
+	prID := event.PullRequestID()
+	result := &WebhookResult{Event: "pull_request." + event.Action, PullRequestID: prID}
+
+	switch event.Action {
+	case "opened":
+		authorID, err := s.identityRepo.GetUserID(ctx, domain.ProviderGitHub, event.PullRequest.User.Login)
+		if err != nil {
+			return applyPREvent(result, func() error { return err })
+		}
+
+		pr := &domain.PullRequest{
+			PullRequestID:   prID,
+			PullRequestName: event.PullRequest.Title,
+			AuthorID:        authorID,
+			Source: &domain.PullRequestSource{
+				Provider:   domain.ProviderGitHub,
+				Project:    event.Repository.FullName,
+				ExternalID: strconv.Itoa(event.PullRequest.Number),
+			},
+		}
+		if event.PullRequest.Draft {
+			pr.Status = domain.PRStatusDraft
+		}
+
+		return applyPREvent(result, func() error {
+			_, err := s.prService.CreatePR(ctx, pr)
+			return err
+		})
+	case "ready_for_review":
+		return applyPREvent(result, func() error {
+			_, err := s.prService.MarkReady(ctx, prID)
+			return err
+		})
+	case "closed":
+		if event.PullRequest.Merged {
+			// The merge already happened on GitHub, so the approval policy
+			// cannot block it here.
+			return applyPREvent(result, func() error {
+				_, err := s.prService.MergePR(ctx, prID, true)
+				return err
+			})
+		}
+		return applyPREvent(result, func() error {
+			_, err := s.prService.ClosePR(ctx, prID, domain.CloseReasonManual)
+			return err
+		})
+	case "synchronize":
+		return applyPREvent(result, func() error {
+			return s.prService.TouchPR(ctx, prID)
+		})
+	case "reopened":
+		return applyPREvent(result, func() error {
+			_, err := s.prService.ReopenPR(ctx, prID)
+			return err
+		})
+	default:
+		result.Status = WebhookStatusIgnored
+		result.Reason = "action is not handled"
+		return result, nil
+	}
+}
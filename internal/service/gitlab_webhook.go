@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type GitLabMergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	User       struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		ID                int    `json:"id"`
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		State  string `json:"state"`
+		Action string `json:"action"`
+		Draft  bool   `json:"draft"`
+	} `json:"object_attributes"`
+	Changes struct {
+		Draft *struct {
+			Previous bool `json:"previous"`
+			Current  bool `json:"current"`
+		} `json:"draft,omitempty"`
+	} `json:"changes"`
+}
+
+func (e *GitLabMergeRequestEvent) PullRequestID() string {
+	return fmt.Sprintf("gitlab:%s!%d", e.Project.PathWithNamespace, e.ObjectAttributes.IID)
+}
+
+func VerifyGitLabToken(expected, token string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+func (s *IntegrationService) HandleGitLabMergeRequest(ctx context.Context, event *GitLabMergeRequestEvent) (*WebhookResult, error) {
+	prID := event.PullRequestID()
+	result := &WebhookResult{Event: "merge_request." + event.ObjectAttributes.Action, PullRequestID: prID}
+
+	switch event.ObjectAttributes.Action {
+	case "open":
+		// For "open" hooks the acting user is the MR author.
+		authorID, err := s.identityRepo.GetUserID(ctx, domain.ProviderGitLab, event.User.Username)
+		if err != nil {
+			return applyPREvent(result, func() error { return err })
+		}
+
+		pr := &domain.PullRequest{
+			PullRequestID:   prID,
+			PullRequestName: event.ObjectAttributes.Title,
+			AuthorID:        authorID,
+			Source: &domain.PullRequestSource{
+				Provider:   domain.ProviderGitLab,
+				Project:    event.Project.PathWithNamespace,
+				ExternalID: strconv.Itoa(event.ObjectAttributes.IID),
+			},
+		}
+		if event.ObjectAttributes.Draft {
+			pr.Status = domain.PRStatusDraft
+		}
+
+		return applyPREvent(result, func() error {
+			_, err := s.prService.CreatePR(ctx, pr)
+			return err
+		})
+	case "update":
+		if draft := event.Changes.Draft; draft != nil && draft.Previous && !draft.Current {
+			return applyPREvent(result, func() error {
+				_, err := s.prService.MarkReady(ctx, prID)
+				return err
+			})
+		}
+		return applyPREvent(result, func() error {
+			return s.prService.TouchPR(ctx, prID)
+		})
+	case "merge":
+		// The merge already happened in GitLab, so the approval policy
+		// cannot block it here.
+		return applyPREvent(result, func() error {
+			_, err := s.prService.MergePR(ctx, prID, true)
+			return err
+		})
+	case "close":
+		return applyPREvent(result, func() error {
+			_, err := s.prService.ClosePR(ctx, prID, domain.CloseReasonManual)
+			return err
+		})
+	case "reopen":
+		return applyPREvent(result, func() error {
+			_, err := s.prService.ReopenPR(ctx, prID)
+			return err
+		})
+	}
+
+	result.Status = WebhookStatusIgnored
+	result.Reason = "action is not handled"
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+type IntegrationService struct {
+	identityRepo *repository.ExternalIdentityRepository
+	prService    *PullRequestService
+}
+
+func NewIntegrationService(identityRepo *repository.ExternalIdentityRepository, prService *PullRequestService) *IntegrationService {
+	return &IntegrationService{
+		identityRepo: identityRepo,
+		prService:    prService,
+	}
+}
+
+type WebhookResult struct {
+	Status        string `json:"status"`
+	Event         string `json:"event"`
+	PullRequestID string `json:"pull_request_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+const (
+	WebhookStatusProcessed = "processed"
+	WebhookStatusIgnored   = "ignored"
+)
+
+func (s *IntegrationService) MapUser(ctx context.Context, identity *domain.ExternalIdentity) error {
+	return s.identityRepo.UpsertIdentity(ctx, identity)
+}
+
+func (s *IntegrationService) ListMappings(ctx context.Context, provider string) ([]domain.ExternalIdentity, error) {
+	return s.identityRepo.ListIdentities(ctx, provider)
+}
+
+func (s *IntegrationService) UnmapUser(ctx context.Context, provider, externalLogin string) error {
+	return s.identityRepo.DeleteIdentity(ctx, provider, externalLogin)
+}
+
+// applyPREvent runs a PR operation triggered by an external system. Domain
+// errors (unknown PR, already merged, etc.) are acknowledged as ignored so the
+// provider does not keep redelivering the hook; other errors are returned.
+func applyPREvent(result *WebhookResult, op func() error) (*WebhookResult, error) {
+	if err := op(); err != nil {
+		if domainErr, ok := err.(*domain.Error); ok {
+			result.Status = WebhookStatusIgnored
+			result.Reason = domainErr.Message
+			return result, nil
+		}
+		return nil, err
+	}
+
+	result.Status = WebhookStatusProcessed
+	return result, nil
+}
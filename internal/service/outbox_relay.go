@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/auth"
+	"github.com/pavel/avitotech_previewer/internal/config"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const (
+	outboxBatchSize      = 100
+	outboxLease          = time.Minute
+	outboxInitialBackoff = time.Second
+	outboxMaxBackoff     = 5 * time.Minute
+	// outboxRetentionBatchSize bounds a single delete, so that retention does
+	// not hold locks on a large part of the table at once.
+	outboxRetentionBatchSize = 1000
+)
+
+// EventSink receives events relayed from the outbox. Delivery is at least
+// once: an event is retried on the sinks that have not accepted it yet, and a
+// sink may still see it twice if the relay dies right after publishing, so
+// sinks and their consumers must tolerate duplicates (use Event.ID).
+type EventSink interface {
+	Name() string
+	Publish(ctx context.Context, event domain.Event) error
+}
+
+type OutboxRelay struct {
+	outboxRepo *repository.OutboxRepository
+	cfg        config.OutboxConfig
+	sinks      []EventSink
+}
+
+func NewOutboxRelay(outboxRepo *repository.OutboxRepository, cfg config.OutboxConfig, sinks ...EventSink) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo: outboxRepo,
+		cfg:        cfg,
+		sinks:      sinks,
+	}
+}
+
+func (r *OutboxRelay) ProcessOutbox(ctx context.Context) error {
+	events, err := r.outboxRepo.ClaimPending(ctx, outboxBatchSize, outboxLease)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err := r.publish(ctx, event); err != nil {
+			attempt := event.Attempts + 1
+			var nextAttemptAt *time.Time
+			if attempt < r.cfg.MaxAttempts {
+				next := time.Now().Add(backoffDelay(outboxInitialBackoff, outboxMaxBackoff, attempt))
+				nextAttemptAt = &next
+			} else {
+				log.Printf("Giving up on outbox event %d after %d attempts: %v", event.ID, attempt, err)
+			}
+			if markErr := r.outboxRepo.MarkFailed(ctx, event.ID, err.Error(), nextAttemptAt); markErr != nil {
+				log.Printf("Failed to record outbox failure for event %d: %v", event.ID, markErr)
+			}
+			continue
+		}
+
+		if err := r.outboxRepo.MarkPublished(ctx, event.ID); err != nil {
+			log.Printf("Failed to mark outbox event %d as published: %v", event.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// PurgeOutbox deletes events that were published to every sink or
+// dead-lettered longer than the retention ago. The event stream cannot return
+// them afterwards, so readers must not fall further behind than that.
+func (r *OutboxRelay) PurgeOutbox(ctx context.Context) error {
+	if r.cfg.Retention == 0 {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-r.cfg.Retention)
+	var total int64
+	for {
+		deleted, err := r.outboxRepo.DeleteFinishedBefore(ctx, cutoff, outboxRetentionBatchSize)
+		if err != nil {
+			return err
+		}
+		total += deleted
+		if deleted < outboxRetentionBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Deleted %d outbox events older than %s", total, r.cfg.Retention)
+	}
+	return nil
+}
+
+// publish hands the event to the sinks that have not accepted it yet, in the
+// context of its organization, so that the settings and recipients they look
+// up are the organization's own. A failing sink does not hold back the others.
+func (r *OutboxRelay) publish(ctx context.Context, event domain.Event) error {
+	ctx = auth.WithOrganization(ctx, event.OrganizationID)
+
+	var errs []error
+	for _, sink := range r.sinks {
+		if slices.Contains(event.PublishedSinks, sink.Name()) {
+			continue
+		}
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s sink: %w", sink.Name(), err))
+			continue
+		}
+		if err := r.outboxRepo.MarkSinkPublished(ctx, event.ID, sink.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, event domain.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	log.Printf("Event %d %s: %s", event.ID, event.Type, data)
+	return nil
+}
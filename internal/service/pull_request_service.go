@@ -2,151 +2,504 @@ package service
 
 import (
 	"context"
-	"math/rand"
+	"log"
+	"time"
 
 	"github.com/pavel/avitotech_previewer/internal/domain"
 	"github.com/pavel/avitotech_previewer/internal/repository"
 )
 
+const (
+	reviewQueueBatchSize = 100
+	// reviewQueueRetryInterval is how long a queued PR for which no reviewer
+	// could be picked is skipped by the queue.
+	reviewQueueRetryInterval = 10 * time.Minute
+)
+
 type PullRequestService struct {
-	prRepo   *repository.PullRequestRepository
-	userRepo *repository.UserRepository
+	prRepo    *repository.PullRequestRepository
+	userRepo  *repository.UserRepository
+	teamRepo  *repository.TeamRepository
+	selectors map[string]ReviewerSelector
 }
 
-func NewPullRequestService(prRepo *repository.PullRequestRepository, userRepo *repository.UserRepository) *PullRequestService {
+func NewPullRequestService(prRepo *repository.PullRequestRepository, userRepo *repository.UserRepository, teamRepo *repository.TeamRepository) *PullRequestService {
 	return &PullRequestService{
-		prRepo:   prRepo,
-		userRepo: userRepo,
+		prRepo:    prRepo,
+		userRepo:  userRepo,
+		teamRepo:  teamRepo,
+		selectors: DefaultReviewerSelectors(),
+	}
+}
+
+func (s *PullRequestService) RegisterSelector(strategy string, selector ReviewerSelector) {
+	s.selectors[strategy] = selector
+}
+
+func (s *PullRequestService) selectorFor(strategy string) ReviewerSelector {
+	if selector, ok := s.selectors[strategy]; ok {
+		return selector
 	}
+	return s.selectors[domain.StrategyLeastLoaded]
+}
+
+type BorrowedReviewer struct {
+	UserID   string `json:"user_id"`
+	TeamName string `json:"team_name"`
+}
+
+type CreatePRResult struct {
+	PR                *domain.PullRequest `json:"pr"`
+	RequiredReviewers int                 `json:"required_reviewers"`
+	MissingReviewers  int                 `json:"missing_reviewers"`
+	QueuedReviewers   int                 `json:"queued_reviewers,omitempty"`
+	BorrowedReviewers []BorrowedReviewer  `json:"borrowed_reviewers,omitempty"`
+}
+
+type ReassignResult struct {
+	NewReviewerID string `json:"replaced_by"`
+	BorrowedFrom  string `json:"borrowed_from,omitempty"`
+}
+
+type reviewerPick struct {
+	selected   []string
+	borrowed   []BorrowedReviewer
+	atCapacity int
+}
+
+// pickReviewers selects up to count reviewers from the team itself and, if the
+// team cannot provide enough, borrows the rest from its fallback teams in
+// priority order. Candidates at their open review limit are skipped.
+func (s *PullRequestService) pickReviewers(ctx context.Context, team *domain.Team, exclude []string, count int) (*reviewerPick, error) {
+	selector := s.selectorFor(team.ReviewerStrategy)
+	pick := &reviewerPick{}
+
+	teams := []string{team.TeamName}
+	fallbackTeams, err := s.teamRepo.GetFallbackTeams(ctx, team.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	teams = append(teams, fallbackTeams...)
+
+	for _, teamName := range teams {
+		if len(pick.selected) >= count {
+			break
+		}
+
+		candidates, err := s.prRepo.GetReviewerCandidates(ctx, teamName, append(exclude, pick.selected...))
+		if err != nil {
+			return nil, err
+		}
+
+		available := make([]domain.ReviewerCandidate, 0, len(candidates))
+		for _, candidate := range candidates {
+			if candidate.AtCapacity() {
+				pick.atCapacity++
+				continue
+			}
+			available = append(available, candidate)
+		}
+
+		for _, userID := range selector.Select(available, count-len(pick.selected)) {
+			pick.selected = append(pick.selected, userID)
+			if teamName != team.TeamName {
+				pick.borrowed = append(pick.borrowed, BorrowedReviewer{UserID: userID, TeamName: teamName})
+			}
+		}
+	}
+
+	return pick, nil
 }
 
 func (s *PullRequestService) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
 	return s.prRepo.GetPR(ctx, prID)
 }
 
-func (s *PullRequestService) CreatePR(ctx context.Context, pr *domain.PullRequest) (*domain.PullRequest, error) {
-	pr.Status = "OPEN"
+func (s *PullRequestService) CreatePR(ctx context.Context, pr *domain.PullRequest) (*CreatePRResult, error) {
+	if pr.Status != domain.PRStatusDraft {
+		pr.Status = domain.PRStatusOpen
+	}
 
-	teamName, err := s.prRepo.GetUserTeam(ctx, pr.AuthorID)
+	if pr.Status == domain.PRStatusDraft {
+		if err := s.prRepo.CreatePR(ctx, pr, nil); err != nil {
+			return nil, err
+		}
+		return &CreatePRResult{PR: pr}, nil
+	}
+
+	result, err := s.planInitialReviewers(ctx, pr)
 	if err != nil {
 		return nil, err
 	}
 
-	reviewerCandidates, err := s.prRepo.GetTeamActiveUsers(ctx, teamName, pr.AuthorID)
+	if err := s.prRepo.CreatePR(ctx, pr, pr.AssignedReviewers); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// planInitialReviewers picks reviewers for a PR that is becoming OPEN and
+// applies the team overload policy. The selection is stored in
+// pr.AssignedReviewers and pr.PendingReviewers; persisting it is up to the caller.
+func (s *PullRequestService) planInitialReviewers(ctx context.Context, pr *domain.PullRequest) (*CreatePRResult, error) {
+	teamName, err := s.prRepo.GetUserTeam(ctx, pr.AuthorID)
 	if err != nil {
 		return nil, err
 	}
 
-	selectedReviewers := selectRandomReviewers(reviewerCandidates, 2)
+	team, err := s.teamRepo.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := s.prRepo.CreatePR(ctx, pr, selectedReviewers); err != nil {
+	pick, err := s.pickReviewers(ctx, team, []string{pr.AuthorID}, team.ReviewersCount)
+	if err != nil {
 		return nil, err
 	}
 
-	pr.AssignedReviewers = selectedReviewers
-	return pr, nil
+	missing := team.ReviewersCount - len(pick.selected)
+	pr.PendingReviewers = 0
+	if missing > 0 && pick.atCapacity > 0 {
+		switch team.OverloadPolicy {
+		case domain.OverloadPolicyQueue:
+			pr.PendingReviewers = missing
+		default:
+			if len(pick.selected) == 0 {
+				return nil, &domain.Error{Code: "CAPACITY_EXCEEDED", Message: "all reviewer candidates are at capacity"}
+			}
+		}
+	}
+
+	pr.AssignedReviewers = pick.selected
+	return &CreatePRResult{
+		PR:                pr,
+		RequiredReviewers: team.ReviewersCount,
+		MissingReviewers:  missing,
+		QueuedReviewers:   pr.PendingReviewers,
+		BorrowedReviewers: pick.borrowed,
+	}, nil
 }
 
-func (s *PullRequestService) MergePR(ctx context.Context, prID string) (*domain.PullRequest, error) {
-	return s.prRepo.MergePR(ctx, prID)
+func (s *PullRequestService) MarkReady(ctx context.Context, prID string) (*CreatePRResult, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Status != domain.PRStatusDraft {
+		return nil, &domain.Error{Code: "PR_NOT_DRAFT", Message: "PR is not a draft"}
+	}
+
+	return s.openPR(ctx, pr)
 }
 
-func (s *PullRequestService) ReassignReviewer(ctx context.Context, prID string, oldReviewerID string) (string, error) {
+func (s *PullRequestService) ReopenPR(ctx context.Context, prID string) (*CreatePRResult, error) {
 	pr, err := s.prRepo.GetPR(ctx, prID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if pr.Status == "MERGED" {
-		return "", &domain.Error{Code: "PR_MERGED", Message: "cannot reassign on merged PR"}
+	if pr.Status != domain.PRStatusClosed {
+		return nil, &domain.Error{Code: "PR_NOT_CLOSED", Message: "only closed PR can be reopened"}
 	}
 
-	if !contains(pr.AssignedReviewers, oldReviewerID) {
-		return "", &domain.Error{Code: "NOT_ASSIGNED", Message: "reviewer is not assigned to this PR"}
+	if len(pr.AssignedReviewers) > 0 {
+		if err := s.prRepo.OpenPR(ctx, prID, domain.PRStatusClosed, nil, 0, domain.AssignmentReasonReopen); err != nil {
+			return nil, err
+		}
+		reopened, err := s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return nil, err
+		}
+		return &CreatePRResult{PR: reopened}, nil
 	}
 
-	teamName, err := s.prRepo.GetUserTeam(ctx, oldReviewerID)
+	return s.openPR(ctx, pr)
+}
+
+func (s *PullRequestService) openPR(ctx context.Context, pr *domain.PullRequest) (*CreatePRResult, error) {
+	fromStatus := pr.Status
+	reason := domain.AssignmentReasonReopen
+	if fromStatus == domain.PRStatusDraft {
+		reason = domain.AssignmentReasonReady
+	}
+
+	result, err := s.planInitialReviewers(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.prRepo.OpenPR(ctx, pr.PullRequestID, fromStatus, pr.AssignedReviewers, pr.PendingReviewers, reason); err != nil {
+		return nil, err
+	}
+
+	result.PR, err = s.prRepo.GetPR(ctx, pr.PullRequestID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	reviewerCandidates, err := s.prRepo.GetTeamActiveUsers(ctx, teamName, oldReviewerID)
+	return result, nil
+}
+
+func (s *PullRequestService) ClosePR(ctx context.Context, prID, reason string) (*domain.PullRequest, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	reviewerCandidates = excludeUser(reviewerCandidates, pr.AuthorID)
+	switch pr.Status {
+	case domain.PRStatusMerged:
+		return nil, &domain.Error{Code: "PR_MERGED", Message: "cannot close merged PR"}
+	case domain.PRStatusClosed:
+		return pr, nil
+	}
 
-	reviewerCandidates = excludeUsers(reviewerCandidates, pr.AssignedReviewers)
+	if err := s.prRepo.ClosePR(ctx, prID, reason); err != nil {
+		return nil, err
+	}
 
-	if len(reviewerCandidates) == 0 {
-		return "", &domain.Error{Code: "NO_CANDIDATE", Message: "no active replacement candidate in team"}
+	return s.prRepo.GetPR(ctx, prID)
+}
+
+func (s *PullRequestService) TouchPR(ctx context.Context, prID string) error {
+	return s.prRepo.TouchPR(ctx, prID)
+}
+
+func (s *PullRequestService) MergePR(ctx context.Context, prID string, adminOverride bool) (*domain.PullRequest, error) {
+	current, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
 	}
 
-	newReviewerID := reviewerCandidates[rand.Intn(len(reviewerCandidates))]
+	switch current.Status {
+	case domain.PRStatusDraft:
+		return nil, &domain.Error{Code: "PR_DRAFT", Message: "cannot merge draft PR"}
+	case domain.PRStatusClosed:
+		return nil, &domain.Error{Code: "PR_CLOSED", Message: "cannot merge closed PR"}
+	}
 
-	newReviewers := replaceUser(pr.AssignedReviewers, oldReviewerID, newReviewerID)
+	if adminOverride {
+		log.Printf("Merging PR %s with admin override, approval policy skipped", prID)
+	} else if err := s.checkMergeAllowed(ctx, current); err != nil {
+		return nil, err
+	}
 
-	if err := s.prRepo.UpdatePRReviewers(ctx, prID, newReviewers); err != nil {
-		return "", err
+	pr, err := s.prRepo.MergePR(ctx, prID)
+	if err != nil {
+		return nil, err
 	}
 
-	return newReviewerID, nil
-}
+	if len(pr.AssignedReviewers) > 0 {
+		if err := s.prRepo.ResumeQueuedPRs(ctx, pr.AssignedReviewers); err != nil {
+			log.Printf("Failed to resume queued PRs after merge of %s: %v", prID, err)
+		}
+		if err := s.ProcessReviewQueue(ctx); err != nil {
+			log.Printf("Failed to process review queue after merge of %s: %v", prID, err)
+		}
+	}
 
-func (s *PullRequestService) GetUserReviewPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error) {
-	return s.prRepo.GetUserReviewPRs(ctx, userID)
+	return pr, nil
 }
 
-func selectRandomReviewers(candidates []string, max int) []string {
-	if len(candidates) == 0 {
+func (s *PullRequestService) checkMergeAllowed(ctx context.Context, pr *domain.PullRequest) error {
+	if pr.Status == domain.PRStatusMerged {
 		return nil
 	}
 
-	if len(candidates) <= max {
-		shuffled := make([]string, len(candidates))
-		copy(shuffled, candidates)
-		rand.Shuffle(len(shuffled), func(i, j int) {
-			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-		})
-		return shuffled
+	teamName, err := s.prRepo.GetUserTeam(ctx, pr.AuthorID)
+	if err != nil {
+		return err
 	}
 
-	selected := make([]string, max)
-	indices := rand.Perm(len(candidates))
-	for i := 0; i < max; i++ {
-		selected[i] = candidates[indices[i]]
+	team, err := s.teamRepo.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return err
+	}
+
+	if team.RequiredApprovals == 0 {
+		return nil
+	}
+
+	approvals := 0
+	changesRequestedBy := make([]string, 0)
+	for _, review := range pr.Reviews {
+		switch review.State {
+		case domain.ReviewStateApproved:
+			approvals++
+		case domain.ReviewStateChangesRequested:
+			changesRequestedBy = append(changesRequestedBy, review.ReviewerID)
+		}
+	}
+
+	missingApprovals := team.RequiredApprovals - approvals
+	if missingApprovals <= 0 && len(changesRequestedBy) == 0 {
+		return nil
+	}
+	if missingApprovals < 0 {
+		missingApprovals = 0
+	}
+
+	return &domain.Error{
+		Code:    "MERGE_BLOCKED",
+		Message: "PR does not satisfy the team approval policy",
+		Details: map[string]interface{}{
+			"required_approvals":   team.RequiredApprovals,
+			"approvals":            approvals,
+			"missing_approvals":    missingApprovals,
+			"changes_requested_by": changesRequestedBy,
+		},
 	}
-	return selected
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+// ProcessReviewQueue assigns reviewers to queued PRs once capacity frees up.
+// PRs that still get no reviewer are deferred for reviewQueueRetryInterval,
+// so that they do not crowd other PRs out of the batch.
+func (s *PullRequestService) ProcessReviewQueue(ctx context.Context) error {
+	prIDs, err := s.prRepo.GetPRsAwaitingReviewers(ctx, reviewQueueBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, prID := range prIDs {
+		assigned, err := s.assignQueuedReviewers(ctx, prID)
+		if err != nil {
+			log.Printf("Failed to assign queued reviewers for PR %s: %v", prID, err)
+		}
+		if assigned {
+			continue
+		}
+		if err := s.prRepo.DeferQueuedPR(ctx, prID, time.Now().Add(reviewQueueRetryInterval)); err != nil {
+			log.Printf("Failed to defer queued PR %s: %v", prID, err)
 		}
 	}
-	return false
+
+	return nil
 }
 
-func excludeUser(slice []string, userID string) []string {
-	var result []string
-	for _, s := range slice {
-		if s != userID {
-			result = append(result, s)
+func (s *PullRequestService) assignQueuedReviewers(ctx context.Context, prID string) (bool, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return false, err
+	}
+
+	teamName, err := s.prRepo.GetUserTeam(ctx, pr.AuthorID)
+	if err != nil {
+		return false, err
+	}
+
+	team, err := s.teamRepo.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return false, err
+	}
+
+	excluded := append([]string{pr.AuthorID}, pr.AssignedReviewers...)
+	pick, err := s.pickReviewers(ctx, team, excluded, pr.PendingReviewers)
+	if err != nil {
+		return false, err
+	}
+	if len(pick.selected) == 0 {
+		return false, nil
+	}
+
+	if err := s.prRepo.AddPRReviewers(ctx, prID, pick.selected, domain.AssignmentReasonQueue); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *PullRequestService) ReassignReviewer(ctx context.Context, prID string, oldReviewerID string, reason string) (*ReassignResult, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pr.Status {
+	case domain.PRStatusMerged:
+		return nil, &domain.Error{Code: "PR_MERGED", Message: "cannot reassign on merged PR"}
+	case domain.PRStatusClosed:
+		return nil, &domain.Error{Code: "PR_CLOSED", Message: "cannot reassign on closed PR"}
+	}
+
+	if !contains(pr.AssignedReviewers, oldReviewerID) {
+		return nil, &domain.Error{Code: "NOT_ASSIGNED", Message: "reviewer is not assigned to this PR"}
+	}
+
+	teamName, err := s.prRepo.GetUserTeam(ctx, oldReviewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	team, err := s.teamRepo.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := append([]string{oldReviewerID, pr.AuthorID}, pr.AssignedReviewers...)
+	pick, err := s.pickReviewers(ctx, team, excluded, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(pick.selected) == 0 {
+		if pick.atCapacity > 0 {
+			return nil, &domain.Error{Code: "CAPACITY_EXCEEDED", Message: "all replacement candidates are at capacity"}
 		}
+		return nil, &domain.Error{Code: "NO_CANDIDATE", Message: "no active replacement candidate in team"}
 	}
-	return result
+
+	result := &ReassignResult{NewReviewerID: pick.selected[0]}
+	if len(pick.borrowed) > 0 {
+		result.BorrowedFrom = pick.borrowed[0].TeamName
+	}
+
+	newReviewers := replaceUser(pr.AssignedReviewers, oldReviewerID, result.NewReviewerID)
+
+	if err := s.prRepo.UpdatePRReviewers(ctx, prID, newReviewers, reason); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *PullRequestService) SubmitReview(ctx context.Context, prID, reviewerID, state string) (*domain.PullRequest, error) {
+	if !domain.IsValidReviewDecision(state) {
+		return nil, &domain.Error{Code: "INVALID_STATE", Message: "state must be APPROVED, CHANGES_REQUESTED or COMMENTED"}
+	}
+
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pr.Status {
+	case domain.PRStatusMerged:
+		return nil, &domain.Error{Code: "PR_MERGED", Message: "cannot review merged PR"}
+	case domain.PRStatusClosed:
+		return nil, &domain.Error{Code: "PR_CLOSED", Message: "cannot review closed PR"}
+	}
+
+	if err := s.prRepo.SubmitReview(ctx, prID, reviewerID, state); err != nil {
+		return nil, err
+	}
+
+	return s.prRepo.GetPR(ctx, prID)
+}
+
+func (s *PullRequestService) GetAssignmentHistory(ctx context.Context, prID string) ([]domain.ReviewerAssignmentEvent, error) {
+	return s.prRepo.GetAssignmentHistory(ctx, prID)
+}
+
+func (s *PullRequestService) GetUserReviewPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error) {
+	return s.prRepo.GetUserReviewPRs(ctx, userID)
 }
 
-func excludeUsers(slice []string, exclude []string) []string {
-	var result []string
+func contains(slice []string, item string) bool {
 	for _, s := range slice {
-		if !contains(exclude, s) {
-			result = append(result, s)
+		if s == item {
+			return true
 		}
 	}
-	return result
+	return false
 }
 
 func replaceUser(slice []string, old, new string) []string {
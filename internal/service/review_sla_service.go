@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const (
+	overdueReviewBatchSize = 100
+	// escalationRetryInterval is how long an escalation that found no one to
+	// hand the review to waits before it is tried again.
+	escalationRetryInterval = time.Hour
+)
+
+type ReviewSLAService struct {
+	prRepo    *repository.PullRequestRepository
+	prService *PullRequestService
+}
+
+func NewReviewSLAService(prRepo *repository.PullRequestRepository, prService *PullRequestService) *ReviewSLAService {
+	return &ReviewSLAService{
+		prRepo:    prRepo,
+		prService: prService,
+	}
+}
+
+// ProcessOverdueReviews reminds reviewers whose assignment passed the team's
+// reminder threshold and hands the review to someone else once it passes the
+// escalation threshold. Thresholds are counted in business hours.
+//
+// Assignments that cannot be acted on now are deferred, so that they do not
+// come back on every run and crowd newer overdue reviews out of the batch:
+// until the next threshold can pass, or for escalationRetryInterval after a
+// failed escalation.
+func (s *ReviewSLAService) ProcessOverdueReviews(ctx context.Context) error {
+	reviews, err := s.prRepo.GetOverdueReviews(ctx, overdueReviewBatchSize)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, review := range reviews {
+		elapsed := businessHoursBetween(review.AssignedAt, now)
+		reminder := time.Duration(review.SLAReminderHours) * time.Hour
+		escalation := time.Duration(review.SLAEscalationHours) * time.Hour
+
+		// Business hours never pass faster than wall-clock time, so no
+		// threshold can be reached before the remaining time has elapsed.
+		var deferFor time.Duration
+		wait := func(remaining time.Duration) {
+			if deferFor == 0 || remaining < deferFor {
+				deferFor = remaining
+			}
+		}
+
+		if escalation > 0 {
+			if elapsed < escalation {
+				wait(escalation - elapsed)
+			} else {
+				reassigned, err := s.prService.ReassignReviewer(ctx, review.PullRequestID, review.ReviewerID, domain.AssignmentReasonEscalation)
+				if err == nil {
+					log.Printf("Escalated PR %s from overdue reviewer %s to %s", review.PullRequestID, review.ReviewerID, reassigned.NewReviewerID)
+					continue
+				}
+				log.Printf("Failed to escalate PR %s from overdue reviewer %s: %v", review.PullRequestID, review.ReviewerID, err)
+				wait(escalationRetryInterval)
+			}
+		}
+
+		if reminder > 0 && review.RemindedAt == nil {
+			if elapsed < reminder {
+				wait(reminder - elapsed)
+			} else {
+				err := s.prRepo.MarkReviewReminded(ctx, domain.ReviewOverdueData{
+					PullRequestID: review.PullRequestID,
+					ReviewerID:    review.ReviewerID,
+					AssignedAt:    review.AssignedAt,
+					SLAHours:      review.SLAReminderHours,
+				})
+				if err != nil {
+					log.Printf("Failed to remind reviewer %s about PR %s: %v", review.ReviewerID, review.PullRequestID, err)
+				}
+			}
+		}
+
+		if deferFor > 0 {
+			if err := s.prRepo.DeferOverdueCheck(ctx, review.PullRequestID, review.ReviewerID, now.Add(deferFor)); err != nil {
+				log.Printf("Failed to defer SLA check of PR %s for reviewer %s: %v", review.PullRequestID, review.ReviewerID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// businessHoursBetween returns the part of [from, to) that falls on weekdays
+// in UTC.
+func businessHoursBetween(from, to time.Time) time.Duration {
+	from, to = from.UTC(), to.UTC()
+
+	var total time.Duration
+	for from.Before(to) {
+		dayEnd := time.Date(from.Year(), from.Month(), from.Day()+1, 0, 0, 0, 0, time.UTC)
+		if dayEnd.After(to) {
+			dayEnd = to
+		}
+		if wd := from.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			total += dayEnd.Sub(from)
+		}
+		from = dayEnd
+	}
+	return total
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+)
+
+type ReviewerSelector interface {
+	Select(candidates []domain.ReviewerCandidate, count int) []string
+}
+
+func DefaultReviewerSelectors() map[string]ReviewerSelector {
+	return map[string]ReviewerSelector{
+		domain.StrategyRandom:      RandomSelector{},
+		domain.StrategyRoundRobin:  RoundRobinSelector{},
+		domain.StrategyLeastLoaded: LeastLoadedSelector{},
+		domain.StrategyWeighted:    WeightedSelector{},
+	}
+}
+
+type RandomSelector struct{}
+
+func (RandomSelector) Select(candidates []domain.ReviewerCandidate, count int) []string {
+	if len(candidates) == 0 || count <= 0 {
+		return nil
+	}
+
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+
+	selected := make([]string, count)
+	indices := rand.Perm(len(candidates))
+	for i := 0; i < count; i++ {
+		selected[i] = candidates[indices[i]].UserID
+	}
+	return selected
+}
+
+// RoundRobinSelector picks whoever has gone the longest without an assignment,
+// so the rotation survives restarts and works across several instances.
+type RoundRobinSelector struct{}
+
+func (RoundRobinSelector) Select(candidates []domain.ReviewerCandidate, count int) []string {
+	ordered := make([]domain.ReviewerCandidate, len(candidates))
+	copy(ordered, candidates)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].LastAssignedAt, ordered[j].LastAssignedAt
+		switch {
+		case a == nil && b == nil:
+			return ordered[i].UserID < ordered[j].UserID
+		case a == nil:
+			return true
+		case b == nil:
+			return false
+		case a.Equal(*b):
+			return ordered[i].UserID < ordered[j].UserID
+		default:
+			return a.Before(*b)
+		}
+	})
+
+	return firstUserIDs(ordered, count)
+}
+
+// LeastLoadedSelector ranks candidates by the number of OPEN pull requests
+// they already review; candidates with equal load are picked in random order.
+type LeastLoadedSelector struct{}
+
+func (LeastLoadedSelector) Select(candidates []domain.ReviewerCandidate, count int) []string {
+	ordered := make([]domain.ReviewerCandidate, len(candidates))
+	copy(ordered, candidates)
+
+	rand.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].OpenReviews < ordered[j].OpenReviews
+	})
+
+	return firstUserIDs(ordered, count)
+}
+
+type WeightedSelector struct{}
+
+func (WeightedSelector) Select(candidates []domain.ReviewerCandidate, count int) []string {
+	pool := make([]domain.ReviewerCandidate, len(candidates))
+	copy(pool, candidates)
+
+	var selected []string
+	for len(selected) < count && len(pool) > 0 {
+		total := 0
+		for _, c := range pool {
+			total += candidateWeight(c)
+		}
+
+		pick := rand.Intn(total)
+		for i, c := range pool {
+			pick -= candidateWeight(c)
+			if pick < 0 {
+				selected = append(selected, c.UserID)
+				pool = append(pool[:i], pool[i+1:]...)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func candidateWeight(c domain.ReviewerCandidate) int {
+	if c.Weight <= 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+func firstUserIDs(candidates []domain.ReviewerCandidate, count int) []string {
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+	if count <= 0 {
+		return nil
+	}
+
+	selected := make([]string, count)
+	for i := 0; i < count; i++ {
+		selected[i] = candidates[i].UserID
+	}
+	return selected
+}
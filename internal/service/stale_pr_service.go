@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const stalePRBatchSize = 100
+
+type StalePRService struct {
+	prRepo    *repository.PullRequestRepository
+	prService *PullRequestService
+}
+
+func NewStalePRService(prRepo *repository.PullRequestRepository, prService *PullRequestService) *StalePRService {
+	return &StalePRService{
+		prRepo:    prRepo,
+		prService: prService,
+	}
+}
+
+// DryRun lists what the next ProcessStalePRs run would do without changing
+// anything. An empty teamName covers all teams.
+func (s *StalePRService) DryRun(ctx context.Context, teamName string) ([]domain.StalePR, error) {
+	return s.prRepo.GetStalePRs(ctx, teamName, 0)
+}
+
+// ProcessStalePRs marks idle open PRs as stale, notifying their author and
+// reviewers, and closes PRs that stayed stale for the team's grace period.
+func (s *StalePRService) ProcessStalePRs(ctx context.Context) error {
+	prs, err := s.prRepo.GetStalePRs(ctx, "", stalePRBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range prs {
+		switch pr.Action {
+		case domain.StaleActionMark:
+			err := s.prRepo.MarkPRStale(ctx, domain.PRStaleData{
+				PullRequestID:  pr.PullRequestID,
+				AuthorID:       pr.AuthorID,
+				LastActivityAt: pr.LastActivityAt,
+				CloseAfterDays: pr.CloseAfterDays,
+			})
+			if err != nil {
+				log.Printf("Failed to mark PR %s stale: %v", pr.PullRequestID, err)
+			}
+		case domain.StaleActionClose:
+			if _, err := s.prService.ClosePR(ctx, pr.PullRequestID, domain.CloseReasonStale); err != nil {
+				log.Printf("Failed to close stale PR %s: %v", pr.PullRequestID, err)
+				continue
+			}
+			log.Printf("Closed stale PR %s", pr.PullRequestID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pavel/avitotech_previewer/internal/config"
+	"github.com/pavel/avitotech_previewer/internal/domain"
+	"github.com/pavel/avitotech_previewer/internal/repository"
+)
+
+const (
+	webhookDeliveryBatchSize = 50
+	maxDeliveryErrorLength   = 1000
+)
+
+type WebhookService struct {
+	webhookRepo *repository.WebhookRepository
+	client      *http.Client
+	cfg         config.WebhooksConfig
+}
+
+func NewWebhookService(webhookRepo *repository.WebhookRepository, cfg config.WebhooksConfig) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		client:      &http.Client{Timeout: cfg.Timeout},
+		cfg:         cfg,
+	}
+}
+
+func (s *WebhookService) AddSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	target, err := url.Parse(sub.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return &domain.Error{Code: "INVALID_URL", Message: "url must be an absolute http(s) URL"}
+	}
+
+	for _, eventType := range sub.Events {
+		if !domain.IsValidEventType(eventType) {
+			return &domain.Error{
+				Code:    "INVALID_EVENT",
+				Message: fmt.Sprintf("unknown event type %q", eventType),
+				Details: map[string]interface{}{"allowed": domain.EventTypes},
+			}
+		}
+	}
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	return s.webhookRepo.CreateSubscription(ctx, sub)
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return s.webhookRepo.ListSubscriptions(ctx)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, subscriptionID int) error {
+	return s.webhookRepo.DeleteSubscription(ctx, subscriptionID)
+}
+
+func (s *WebhookService) GetDeliveries(ctx context.Context, subscriptionID int, status string, limit int) ([]domain.WebhookDelivery, error) {
+	return s.webhookRepo.GetDeliveries(ctx, subscriptionID, status, limit)
+}
+
+func (s *WebhookService) Redeliver(ctx context.Context, deliveryID int64) error {
+	return s.webhookRepo.Redeliver(ctx, deliveryID)
+}
+
+func (s *WebhookService) Name() string {
+	return "webhook"
+}
+
+// Publish fans an outbox event out into per-subscription deliveries, which are
+// then sent and retried independently by ProcessDeliveries.
+func (s *WebhookService) Publish(ctx context.Context, event domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+	}
+
+	_, err = s.webhookRepo.EnqueueEvent(ctx, event.Type, payload)
+	return err
+}
+
+// ProcessDeliveries sends due deliveries and schedules failed ones for a retry
+// with exponential backoff until the attempt limit is reached.
+func (s *WebhookService) ProcessDeliveries(ctx context.Context) error {
+	deliveries, err := s.webhookRepo.ClaimDueDeliveries(ctx, webhookDeliveryBatchSize, s.cfg.Timeout*2)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, sendErr := s.send(ctx, delivery)
+		if sendErr == nil {
+			if err := s.webhookRepo.MarkDelivered(ctx, delivery.ID, statusCode); err != nil {
+				log.Printf("Failed to mark delivery %d as delivered: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		attempt := delivery.Attempts + 1
+		var nextAttemptAt *time.Time
+		if attempt < s.cfg.MaxAttempts {
+			next := time.Now().Add(backoffDelay(s.cfg.InitialBackoff, s.cfg.MaxBackoff, attempt))
+			nextAttemptAt = &next
+		}
+
+		errMsg := sendErr.Error()
+		if len(errMsg) > maxDeliveryErrorLength {
+			errMsg = errMsg[:maxDeliveryErrorLength]
+		}
+
+		if err := s.webhookRepo.MarkAttemptFailed(ctx, delivery.ID, statusCode, errMsg, nextAttemptAt); err != nil {
+			log.Printf("Failed to record attempt for delivery %d: %v", delivery.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *WebhookService) send(ctx context.Context, delivery repository.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pr-reviewer-webhooks")
+	req.Header.Set("X-Previewer-Event", delivery.EventType)
+	req.Header.Set("X-Previewer-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Previewer-Signature-256", SignWebhookPayload(delivery.Secret, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoffDelay doubles initial for every attempt after the first, capped at max.
+func backoffDelay(initial, max time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// SignWebhookPayload returns the value of the X-Previewer-Signature-256 header:
+// the hex HMAC-SHA256 of the body keyed with the subscription secret.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type Job func(ctx context.Context) error
+
+func Run(ctx context.Context, name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		log.Printf("Worker %s disabled", name)
+		return
+	}
+
+	log.Printf("Worker %s started, interval %s", name, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Worker %s stopped", name)
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.Printf("Worker %s failed: %v", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,6 @@
+ALTER TABLE teams
+    ADD COLUMN reviewer_strategy VARCHAR(50) NOT NULL DEFAULT 'random'
+    CHECK (reviewer_strategy IN ('random', 'round_robin', 'least_loaded', 'weighted'));
+
+ALTER TABLE users
+    ADD COLUMN review_weight INTEGER NOT NULL DEFAULT 1 CHECK (review_weight > 0);
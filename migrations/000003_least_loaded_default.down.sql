@@ -0,0 +1 @@
+ALTER TABLE teams ALTER COLUMN reviewer_strategy SET DEFAULT 'random';
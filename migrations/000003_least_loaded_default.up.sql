@@ -0,0 +1,7 @@
+ALTER TABLE teams ALTER COLUMN reviewer_strategy SET DEFAULT 'least_loaded';
+
+-- Teams whose settings were never changed are on the old implicit default and
+-- move to the new one. A team that was configured through updateSettings
+-- (updated_at moved past created_at) keeps its strategy, even if it is random.
+UPDATE teams SET reviewer_strategy = 'least_loaded'
+WHERE reviewer_strategy = 'random' AND updated_at IS NOT DISTINCT FROM created_at;
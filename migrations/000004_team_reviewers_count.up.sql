@@ -0,0 +1,3 @@
+ALTER TABLE teams
+    ADD COLUMN reviewers_count INTEGER NOT NULL DEFAULT 2
+    CHECK (reviewers_count BETWEEN 1 AND 5);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_pull_requests_pending;
+
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS pending_reviewers;
+ALTER TABLE teams DROP COLUMN IF EXISTS overload_policy;
+ALTER TABLE teams DROP COLUMN IF EXISTS default_max_open_reviews;
+ALTER TABLE users DROP COLUMN IF EXISTS max_open_reviews;
@@ -0,0 +1,11 @@
+ALTER TABLE users
+    ADD COLUMN max_open_reviews INTEGER NULL CHECK (max_open_reviews > 0);
+
+ALTER TABLE teams
+    ADD COLUMN default_max_open_reviews INTEGER NULL CHECK (default_max_open_reviews > 0),
+    ADD COLUMN overload_policy VARCHAR(50) NOT NULL DEFAULT 'reject' CHECK (overload_policy IN ('reject', 'queue'));
+
+ALTER TABLE pull_requests
+    ADD COLUMN pending_reviewers INTEGER NOT NULL DEFAULT 0 CHECK (pending_reviewers >= 0);
+
+CREATE INDEX idx_pull_requests_pending ON pull_requests(created_at) WHERE pending_reviewers > 0;
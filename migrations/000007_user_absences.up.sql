@@ -0,0 +1,13 @@
+CREATE TABLE user_absences (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    reviews_reassigned_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_user_absences_user_period ON user_absences(user_id, starts_at, ends_at);
+CREATE INDEX idx_user_absences_pending ON user_absences(starts_at) WHERE reviews_reassigned_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS state_updated_at;
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS review_state;
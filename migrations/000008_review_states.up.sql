@@ -0,0 +1,4 @@
+ALTER TABLE pull_request_reviewers
+    ADD COLUMN review_state VARCHAR(50) NOT NULL DEFAULT 'PENDING'
+        CHECK (review_state IN ('PENDING', 'APPROVED', 'CHANGES_REQUESTED', 'COMMENTED')),
+    ADD COLUMN state_updated_at TIMESTAMP WITH TIME ZONE NULL;
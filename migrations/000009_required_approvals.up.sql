@@ -0,0 +1,3 @@
+ALTER TABLE teams
+    ADD COLUMN required_approvals INTEGER NOT NULL DEFAULT 0
+    CHECK (required_approvals BETWEEN 0 AND 5);
@@ -0,0 +1,14 @@
+CREATE TABLE pull_request_reviewer_history (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+    action VARCHAR(50) NOT NULL CHECK (action IN ('ASSIGNED', 'UNASSIGNED')),
+    reason VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reviewer_history_pr ON pull_request_reviewer_history(pull_request_id, id);
+
+INSERT INTO pull_request_reviewer_history (pull_request_id, reviewer_id, action, reason, created_at)
+SELECT pull_request_id, reviewer_id, 'ASSIGNED', 'create', assigned_at
+FROM pull_request_reviewers;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_external_identities_updated_at ON external_identities;
+DROP TABLE IF EXISTS external_identities;
@@ -0,0 +1,13 @@
+CREATE TABLE external_identities (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    external_login VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, external_login)
+);
+
+CREATE INDEX idx_external_identities_user ON external_identities(user_id);
+
+CREATE TRIGGER update_external_identities_updated_at BEFORE UPDATE ON external_identities FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,4 @@
+ALTER TABLE pull_requests DROP CONSTRAINT IF EXISTS pull_requests_source_unique;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS external_id;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS source_project;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS source_provider;
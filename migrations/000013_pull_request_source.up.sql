@@ -0,0 +1,11 @@
+ALTER TABLE pull_requests
+    ADD COLUMN source_provider VARCHAR(50) NULL,
+    ADD COLUMN source_project VARCHAR(500) NULL,
+    ADD COLUMN external_id VARCHAR(255) NULL,
+    ADD CONSTRAINT pull_requests_source_unique UNIQUE (source_provider, source_project, external_id);
+
+UPDATE pull_requests
+SET source_provider = 'github',
+    source_project = substring(pull_request_id from '^github:(.+)#[0-9]+$'),
+    external_id = substring(pull_request_id from '#([0-9]+)$')
+WHERE pull_request_id ~ '^github:.+#[0-9]+$';
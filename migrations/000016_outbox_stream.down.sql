@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_events_stream;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS tx_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS user_ids;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS team_name;
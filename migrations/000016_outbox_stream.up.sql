@@ -0,0 +1,6 @@
+ALTER TABLE outbox_events
+    ADD COLUMN team_name VARCHAR(255) NULL,
+    ADD COLUMN user_ids TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN tx_id XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX idx_outbox_events_stream ON outbox_events(tx_id, id);
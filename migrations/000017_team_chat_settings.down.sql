@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_team_chat_settings_updated_at ON team_chat_settings;
+DROP TABLE IF EXISTS team_chat_settings;
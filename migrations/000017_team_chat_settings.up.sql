@@ -0,0 +1,12 @@
+CREATE TABLE team_chat_settings (
+    team_name VARCHAR(255) PRIMARY KEY REFERENCES teams(team_name) ON DELETE CASCADE,
+    webhook_url VARCHAR(2000) NOT NULL,
+    channel VARCHAR(255) NOT NULL DEFAULT '',
+    assigned_template TEXT NOT NULL DEFAULT '',
+    reassigned_template TEXT NOT NULL DEFAULT '',
+    is_enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_team_chat_settings_updated_at BEFORE UPDATE ON team_chat_settings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_email_digest;
+ALTER TABLE users DROP COLUMN IF EXISTS email_digest_sent_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_notifications;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN email VARCHAR(320) NULL,
+    ADD COLUMN email_notifications VARCHAR(20) NOT NULL DEFAULT 'off' CHECK (email_notifications IN ('off', 'immediate', 'digest')),
+    ADD COLUMN email_digest_sent_at TIMESTAMP WITH TIME ZONE NULL;
+
+CREATE INDEX idx_users_email_digest ON users(email_digest_sent_at) WHERE email_notifications = 'digest';
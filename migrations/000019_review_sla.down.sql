@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_pr_reviewers_pending;
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS sla_reminded_at;
+ALTER TABLE teams DROP COLUMN IF EXISTS sla_escalation_hours;
+ALTER TABLE teams DROP COLUMN IF EXISTS sla_reminder_hours;
@@ -0,0 +1,8 @@
+ALTER TABLE teams
+    ADD COLUMN sla_reminder_hours INTEGER NOT NULL DEFAULT 0 CHECK (sla_reminder_hours >= 0),
+    ADD COLUMN sla_escalation_hours INTEGER NOT NULL DEFAULT 0 CHECK (sla_escalation_hours >= 0);
+
+ALTER TABLE pull_request_reviewers
+    ADD COLUMN sla_reminded_at TIMESTAMP WITH TIME ZONE NULL;
+
+CREATE INDEX idx_pr_reviewers_pending ON pull_request_reviewers(assigned_at) WHERE review_state = 'PENDING';
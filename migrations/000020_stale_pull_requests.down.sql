@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_pull_requests_open_activity;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS stale_at;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS last_activity_at;
+ALTER TABLE teams DROP COLUMN IF EXISTS stale_close_after_days;
+ALTER TABLE teams DROP COLUMN IF EXISTS stale_after_days;
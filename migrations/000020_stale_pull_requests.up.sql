@@ -0,0 +1,17 @@
+ALTER TABLE teams
+    ADD COLUMN stale_after_days INTEGER NOT NULL DEFAULT 0 CHECK (stale_after_days >= 0),
+    ADD COLUMN stale_close_after_days INTEGER NOT NULL DEFAULT 0 CHECK (stale_close_after_days >= 0);
+
+ALTER TABLE pull_requests
+    ADD COLUMN last_activity_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN stale_at TIMESTAMP WITH TIME ZONE NULL;
+
+UPDATE pull_requests p
+SET last_activity_at = COALESCE(GREATEST(
+    p.created_at,
+    p.updated_at,
+    (SELECT MAX(h.created_at) FROM pull_request_reviewer_history h WHERE h.pull_request_id = p.pull_request_id),
+    (SELECT MAX(r.state_updated_at) FROM pull_request_reviewers r WHERE r.pull_request_id = p.pull_request_id)
+), CURRENT_TIMESTAMP);
+
+CREATE INDEX idx_pull_requests_open_activity ON pull_requests(last_activity_at) WHERE status = 'OPEN';
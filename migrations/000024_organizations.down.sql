@@ -0,0 +1,65 @@
+-- Fails if ids collide across organizations; merge or delete them first.
+DROP INDEX IF EXISTS idx_outbox_events_organization;
+DROP INDEX IF EXISTS idx_audit_log_organization;
+DROP INDEX IF EXISTS idx_audit_log_entity;
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id);
+DROP INDEX IF EXISTS idx_users_team_active;
+CREATE INDEX idx_users_team_active ON users(team_name, is_active);
+
+ALTER TABLE team_chat_settings DROP CONSTRAINT team_chat_settings_team_fkey;
+ALTER TABLE external_identities DROP CONSTRAINT external_identities_user_fkey;
+ALTER TABLE user_absences DROP CONSTRAINT user_absences_user_fkey;
+ALTER TABLE team_fallbacks DROP CONSTRAINT team_fallbacks_fallback_team_fkey;
+ALTER TABLE team_fallbacks DROP CONSTRAINT team_fallbacks_team_fkey;
+ALTER TABLE pull_request_reviewer_history DROP CONSTRAINT pull_request_reviewer_history_reviewer_fkey;
+ALTER TABLE pull_request_reviewer_history DROP CONSTRAINT pull_request_reviewer_history_pull_request_fkey;
+ALTER TABLE pull_request_reviewers DROP CONSTRAINT pull_request_reviewers_reviewer_fkey;
+ALTER TABLE pull_request_reviewers DROP CONSTRAINT pull_request_reviewers_pull_request_fkey;
+ALTER TABLE pull_requests DROP CONSTRAINT pull_requests_author_fkey;
+ALTER TABLE users DROP CONSTRAINT users_team_fkey;
+
+ALTER TABLE team_chat_settings DROP CONSTRAINT team_chat_settings_pkey;
+ALTER TABLE external_identities DROP CONSTRAINT external_identities_organization_login_key;
+ALTER TABLE team_fallbacks DROP CONSTRAINT team_fallbacks_organization_team_fallback_key;
+ALTER TABLE pull_request_reviewers DROP CONSTRAINT pull_request_reviewers_organization_pr_reviewer_key;
+ALTER TABLE pull_requests DROP CONSTRAINT pull_requests_source_unique;
+ALTER TABLE pull_requests DROP CONSTRAINT pull_requests_organization_pull_request_id_key;
+ALTER TABLE users DROP CONSTRAINT users_organization_user_id_key;
+ALTER TABLE teams DROP CONSTRAINT teams_organization_team_name_key;
+
+ALTER TABLE teams ADD CONSTRAINT teams_team_name_key UNIQUE (team_name);
+ALTER TABLE users ADD CONSTRAINT users_user_id_key UNIQUE (user_id);
+ALTER TABLE pull_requests ADD CONSTRAINT pull_requests_pull_request_id_key UNIQUE (pull_request_id);
+ALTER TABLE pull_requests ADD CONSTRAINT pull_requests_source_unique UNIQUE (source_provider, source_project, external_id);
+ALTER TABLE pull_request_reviewers ADD CONSTRAINT pull_request_reviewers_pull_request_id_reviewer_id_key UNIQUE (pull_request_id, reviewer_id);
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_team_name_fallback_team_name_key UNIQUE (team_name, fallback_team_name);
+ALTER TABLE external_identities ADD CONSTRAINT external_identities_provider_external_login_key UNIQUE (provider, external_login);
+ALTER TABLE team_chat_settings ADD PRIMARY KEY (team_name);
+
+ALTER TABLE users ADD CONSTRAINT users_team_name_fkey FOREIGN KEY (team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+ALTER TABLE pull_requests ADD CONSTRAINT pull_requests_author_id_fkey FOREIGN KEY (author_id) REFERENCES users(user_id);
+ALTER TABLE pull_request_reviewers ADD CONSTRAINT pull_request_reviewers_pull_request_id_fkey FOREIGN KEY (pull_request_id) REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE;
+ALTER TABLE pull_request_reviewers ADD CONSTRAINT pull_request_reviewers_reviewer_id_fkey FOREIGN KEY (reviewer_id) REFERENCES users(user_id);
+ALTER TABLE pull_request_reviewer_history ADD CONSTRAINT pull_request_reviewer_history_pull_request_id_fkey FOREIGN KEY (pull_request_id) REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE;
+ALTER TABLE pull_request_reviewer_history ADD CONSTRAINT pull_request_reviewer_history_reviewer_id_fkey FOREIGN KEY (reviewer_id) REFERENCES users(user_id);
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_team_name_fkey FOREIGN KEY (team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_fallback_team_name_fkey FOREIGN KEY (fallback_team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+ALTER TABLE user_absences ADD CONSTRAINT user_absences_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
+ALTER TABLE external_identities ADD CONSTRAINT external_identities_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
+ALTER TABLE team_chat_settings ADD CONSTRAINT team_chat_settings_team_name_fkey FOREIGN KEY (team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+
+ALTER TABLE api_keys DROP COLUMN organization_id;
+ALTER TABLE audit_log DROP COLUMN organization_id;
+ALTER TABLE outbox_events DROP COLUMN organization_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN organization_id;
+ALTER TABLE team_chat_settings DROP COLUMN organization_id;
+ALTER TABLE external_identities DROP COLUMN organization_id;
+ALTER TABLE user_absences DROP COLUMN organization_id;
+ALTER TABLE team_fallbacks DROP COLUMN organization_id;
+ALTER TABLE pull_request_reviewer_history DROP COLUMN organization_id;
+ALTER TABLE pull_request_reviewers DROP COLUMN organization_id;
+ALTER TABLE pull_requests DROP COLUMN organization_id;
+ALTER TABLE users DROP COLUMN organization_id;
+ALTER TABLE teams DROP COLUMN organization_id;
+
+DROP TABLE IF EXISTS organizations;
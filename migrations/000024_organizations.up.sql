@@ -0,0 +1,99 @@
+CREATE TABLE organizations (
+    organization_id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (organization_id, name) VALUES ('default', 'Default organization');
+
+-- Existing data belongs to the default organization. The defaults are dropped
+-- at the end so that every write has to name its organization.
+ALTER TABLE teams ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES organizations(organization_id) ON DELETE CASCADE;
+ALTER TABLE users ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pull_requests ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pull_request_reviewers ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pull_request_reviewer_history ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE team_fallbacks ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE user_absences ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE external_identities ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE team_chat_settings ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_subscriptions ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES organizations(organization_id) ON DELETE CASCADE;
+ALTER TABLE outbox_events ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES organizations(organization_id) ON DELETE CASCADE;
+ALTER TABLE audit_log ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES organizations(organization_id) ON DELETE CASCADE;
+-- Keys issued so far worked with the default organization's data and stay
+-- bound to it; only the bootstrap key from the config spans organizations.
+ALTER TABLE api_keys ADD COLUMN organization_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES organizations(organization_id) ON DELETE CASCADE;
+
+ALTER TABLE users DROP CONSTRAINT users_team_name_fkey;
+ALTER TABLE pull_requests DROP CONSTRAINT pull_requests_author_id_fkey;
+ALTER TABLE pull_request_reviewers DROP CONSTRAINT pull_request_reviewers_pull_request_id_fkey;
+ALTER TABLE pull_request_reviewers DROP CONSTRAINT pull_request_reviewers_reviewer_id_fkey;
+ALTER TABLE pull_request_reviewer_history DROP CONSTRAINT pull_request_reviewer_history_pull_request_id_fkey;
+ALTER TABLE pull_request_reviewer_history DROP CONSTRAINT pull_request_reviewer_history_reviewer_id_fkey;
+ALTER TABLE team_fallbacks DROP CONSTRAINT team_fallbacks_team_name_fkey;
+ALTER TABLE team_fallbacks DROP CONSTRAINT team_fallbacks_fallback_team_name_fkey;
+ALTER TABLE user_absences DROP CONSTRAINT user_absences_user_id_fkey;
+ALTER TABLE external_identities DROP CONSTRAINT external_identities_user_id_fkey;
+ALTER TABLE team_chat_settings DROP CONSTRAINT team_chat_settings_team_name_fkey;
+
+ALTER TABLE teams DROP CONSTRAINT teams_team_name_key;
+ALTER TABLE users DROP CONSTRAINT users_user_id_key;
+ALTER TABLE pull_requests DROP CONSTRAINT pull_requests_pull_request_id_key;
+ALTER TABLE pull_requests DROP CONSTRAINT pull_requests_source_unique;
+ALTER TABLE pull_request_reviewers DROP CONSTRAINT pull_request_reviewers_pull_request_id_reviewer_id_key;
+ALTER TABLE team_fallbacks DROP CONSTRAINT team_fallbacks_team_name_fallback_team_name_key;
+ALTER TABLE external_identities DROP CONSTRAINT external_identities_provider_external_login_key;
+ALTER TABLE team_chat_settings DROP CONSTRAINT team_chat_settings_pkey;
+
+ALTER TABLE teams ADD CONSTRAINT teams_organization_team_name_key UNIQUE (organization_id, team_name);
+ALTER TABLE users ADD CONSTRAINT users_organization_user_id_key UNIQUE (organization_id, user_id);
+ALTER TABLE pull_requests ADD CONSTRAINT pull_requests_organization_pull_request_id_key UNIQUE (organization_id, pull_request_id);
+ALTER TABLE pull_requests ADD CONSTRAINT pull_requests_source_unique UNIQUE (organization_id, source_provider, source_project, external_id);
+ALTER TABLE pull_request_reviewers ADD CONSTRAINT pull_request_reviewers_organization_pr_reviewer_key UNIQUE (organization_id, pull_request_id, reviewer_id);
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_organization_team_fallback_key UNIQUE (organization_id, team_name, fallback_team_name);
+ALTER TABLE external_identities ADD CONSTRAINT external_identities_organization_login_key UNIQUE (organization_id, provider, external_login);
+ALTER TABLE team_chat_settings ADD PRIMARY KEY (organization_id, team_name);
+
+ALTER TABLE users ADD CONSTRAINT users_team_fkey
+    FOREIGN KEY (organization_id, team_name) REFERENCES teams(organization_id, team_name) ON DELETE CASCADE;
+ALTER TABLE pull_requests ADD CONSTRAINT pull_requests_author_fkey
+    FOREIGN KEY (organization_id, author_id) REFERENCES users(organization_id, user_id);
+ALTER TABLE pull_request_reviewers ADD CONSTRAINT pull_request_reviewers_pull_request_fkey
+    FOREIGN KEY (organization_id, pull_request_id) REFERENCES pull_requests(organization_id, pull_request_id) ON DELETE CASCADE;
+ALTER TABLE pull_request_reviewers ADD CONSTRAINT pull_request_reviewers_reviewer_fkey
+    FOREIGN KEY (organization_id, reviewer_id) REFERENCES users(organization_id, user_id);
+ALTER TABLE pull_request_reviewer_history ADD CONSTRAINT pull_request_reviewer_history_pull_request_fkey
+    FOREIGN KEY (organization_id, pull_request_id) REFERENCES pull_requests(organization_id, pull_request_id) ON DELETE CASCADE;
+ALTER TABLE pull_request_reviewer_history ADD CONSTRAINT pull_request_reviewer_history_reviewer_fkey
+    FOREIGN KEY (organization_id, reviewer_id) REFERENCES users(organization_id, user_id);
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_team_fkey
+    FOREIGN KEY (organization_id, team_name) REFERENCES teams(organization_id, team_name) ON DELETE CASCADE;
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_fallback_team_fkey
+    FOREIGN KEY (organization_id, fallback_team_name) REFERENCES teams(organization_id, team_name) ON DELETE CASCADE;
+ALTER TABLE user_absences ADD CONSTRAINT user_absences_user_fkey
+    FOREIGN KEY (organization_id, user_id) REFERENCES users(organization_id, user_id) ON DELETE CASCADE;
+ALTER TABLE external_identities ADD CONSTRAINT external_identities_user_fkey
+    FOREIGN KEY (organization_id, user_id) REFERENCES users(organization_id, user_id) ON DELETE CASCADE;
+ALTER TABLE team_chat_settings ADD CONSTRAINT team_chat_settings_team_fkey
+    FOREIGN KEY (organization_id, team_name) REFERENCES teams(organization_id, team_name) ON DELETE CASCADE;
+
+ALTER TABLE teams ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE pull_requests ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE pull_request_reviewers ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE pull_request_reviewer_history ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE team_fallbacks ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE user_absences ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE external_identities ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE team_chat_settings ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE webhook_subscriptions ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE outbox_events ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE audit_log ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN organization_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS idx_users_team_active;
+CREATE INDEX idx_users_team_active ON users(organization_id, team_name, is_active);
+DROP INDEX IF EXISTS idx_audit_log_entity;
+CREATE INDEX idx_audit_log_entity ON audit_log(organization_id, entity_type, entity_id);
+CREATE INDEX idx_audit_log_organization ON audit_log(organization_id, id);
+CREATE INDEX idx_outbox_events_organization ON outbox_events(organization_id) WHERE published_at IS NULL;
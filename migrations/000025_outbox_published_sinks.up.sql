@@ -0,0 +1,3 @@
+-- Sinks that already accepted an event, so that a retry after a failure in
+-- another sink does not deliver it to them again.
+ALTER TABLE outbox_events ADD COLUMN published_sinks TEXT[] NOT NULL DEFAULT '{}';
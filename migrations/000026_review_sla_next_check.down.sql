@@ -0,0 +1 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS sla_next_check_at;
@@ -0,0 +1,5 @@
+-- Overdue reviews that cannot be acted on yet, because the threshold has not
+-- passed in business hours or the escalation found no one to hand over to,
+-- are skipped until this time instead of filling every batch.
+ALTER TABLE pull_request_reviewers
+    ADD COLUMN sla_next_check_at TIMESTAMP WITH TIME ZONE NULL;
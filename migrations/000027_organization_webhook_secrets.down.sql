@@ -0,0 +1,2 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS gitlab_webhook_token;
+ALTER TABLE organizations DROP COLUMN IF EXISTS github_webhook_secret;
@@ -0,0 +1,4 @@
+-- Inbound GitHub/GitLab webhooks of an organization are verified with its own
+-- secret, so one organization cannot post events into another.
+ALTER TABLE organizations ADD COLUMN github_webhook_secret VARCHAR(255) NULL;
+ALTER TABLE organizations ADD COLUMN gitlab_webhook_token VARCHAR(255) NULL;
@@ -0,0 +1,5 @@
+-- Queued PRs for which no reviewer could be picked are skipped until this
+-- time, so that they do not fill every batch and starve newer PRs of other
+-- teams that could be assigned.
+ALTER TABLE pull_requests
+    ADD COLUMN queue_next_attempt_at TIMESTAMP WITH TIME ZONE NULL;
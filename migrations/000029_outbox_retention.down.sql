@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_events_finished;
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE published_at IS NULL;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS failed_at;
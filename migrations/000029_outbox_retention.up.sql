@@ -0,0 +1,9 @@
+-- Events that still fail after OUTBOX_MAX_ATTEMPTS attempts are dead-lettered:
+-- the relay stops retrying them. Published and dead-lettered events are
+-- deleted once they are older than OUTBOX_RETENTION.
+ALTER TABLE outbox_events ADD COLUMN failed_at TIMESTAMP WITH TIME ZONE NULL;
+
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_events_finished ON outbox_events(COALESCE(published_at, failed_at))
+    WHERE published_at IS NOT NULL OR failed_at IS NOT NULL;